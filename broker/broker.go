@@ -0,0 +1,135 @@
+package broker
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	EventCheck    = "check"    // A check result was recorded
+	EventStatus   = "status"   // The status of an endpoint changed
+	EventIncident = "incident" // An incident was opened, acknowledged, escalated or resolved
+)
+
+// subscriberBuffer is the number of events a subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// Event is a change to one of an organization's endpoints
+type Event struct {
+	Type           string      `json:"type"`
+	OrganizationID uint        `json:"-"`
+	EndpointID     int         `json:"endpoint_id"`
+	Time           time.Time   `json:"time"`
+	Data           interface{} `json:"data"`
+}
+
+// StatusChange is the data of a status event
+type StatusChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// IncidentChange is the data of an incident event
+type IncidentChange struct {
+	IncidentID uint   `json:"incident_id"`
+	Status     string `json:"status"` // Incident status after the change
+	Message    string `json:"message"`
+}
+
+// Broker fans out published events to the subscribers of the event's organization
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan Event]struct{}
+	closed      bool
+}
+
+// New creates a broker without subscribers
+func New() *Broker {
+	return &Broker{subscribers: make(map[uint]map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel receiving the events of an organization, and a function to
+// unsubscribe. The channel is closed when the subscriber falls too far behind or
+// the broker is closed, after which the subscriber should start over.
+func (b *Broker) Subscribe(orgID uint) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subscribers[orgID] == nil {
+		b.subscribers[orgID] = make(map[chan Event]struct{})
+	}
+	b.subscribers[orgID][ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(orgID, ch)
+	}
+}
+
+// Publish sends an event to the subscribers of its organization without blocking.
+// Subscribers whose buffer is full are dropped.
+func (b *Broker) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers[event.OrganizationID] {
+		select {
+		case ch <- event:
+		default:
+			b.remove(event.OrganizationID, ch)
+		}
+	}
+}
+
+// Close drops all subscribers and ignores later subscriptions
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for orgID, channels := range b.subscribers {
+		for ch := range channels {
+			b.remove(orgID, ch)
+		}
+	}
+	b.closed = true
+}
+
+// remove closes a subscriber channel if it is still subscribed. b.mu must be held.
+func (b *Broker) remove(orgID uint, ch chan Event) {
+	channels := b.subscribers[orgID]
+	if _, ok := channels[ch]; !ok {
+		return
+	}
+	delete(channels, ch)
+	close(ch)
+	if len(channels) == 0 {
+		delete(b.subscribers, orgID)
+	}
+}
+
+// defaultBroker is the broker the checker, notifier and handlers share
+var defaultBroker = New()
+
+// Publish sends an event through the default broker
+func Publish(event Event) {
+	defaultBroker.Publish(event)
+}
+
+// Subscribe subscribes to the events of an organization on the default broker
+func Subscribe(orgID uint) (<-chan Event, func()) {
+	return defaultBroker.Subscribe(orgID)
+}
+
+// Close closes the default broker, ending all streams
+func Close() {
+	defaultBroker.Close()
+}
@@ -0,0 +1,324 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the server settings
+type Config struct {
+	Server       ServerConfig       `yaml:"server"`
+	Auth         AuthConfig         `yaml:"auth"`
+	Database     DatabaseConfig     `yaml:"database"`
+	Checker      CheckerConfig      `yaml:"checker"`
+	SMTP         SMTPConfig         `yaml:"smtp"`
+	Subscription SubscriptionConfig `yaml:"subscription"`
+}
+
+// ServerConfig holds the HTTP listener settings
+type ServerConfig struct {
+	ListenAddr     string   `yaml:"listen_addr"`
+	TLSCertFile    string   `yaml:"tls_cert_file"` // TLS is enabled when both files are set
+	TLSKeyFile     string   `yaml:"tls_key_file"`
+	TrustedProxies []string `yaml:"trusted_proxies"` // Addresses or CIDR ranges whose X-Forwarded-For header is trusted
+	BaseURL        string   `yaml:"base_url"`        // Public URL of the server, used for links in emails
+}
+
+// TLSEnabled reports whether the server should serve HTTPS
+func (s ServerConfig) TLSEnabled() bool {
+	return s.TLSCertFile != "" && s.TLSKeyFile != ""
+}
+
+// TrustedProxyRanges parses the trusted proxies. A single address is a range of its own.
+func (s ServerConfig) TrustedProxyRanges() ([]*net.IPNet, error) {
+	ranges := make([]*net.IPNet, 0, len(s.TrustedProxies))
+	for _, proxy := range s.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			ranges = append(ranges, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", proxy)
+		}
+		ranges = append(ranges, ipNet)
+	}
+	return ranges, nil
+}
+
+// AuthConfig holds the token signing and login protection settings
+type AuthConfig struct {
+	JWTSecret          string        `yaml:"jwt_secret"`
+	TokenTTL           time.Duration `yaml:"token_ttl"`             // Lifetime of access tokens
+	RefreshTokenTTL    time.Duration `yaml:"refresh_token_ttl"`     // Lifetime of a session without use
+	LoginMaxFailures   int           `yaml:"login_max_failures"`    // Failed logins for an account before it is locked
+	LoginMaxIPFailures int           `yaml:"login_max_ip_failures"` // Failed logins from an IP address before it is blocked
+	LoginLockout       time.Duration `yaml:"login_lockout"`         // How long lockouts last and failures are remembered
+	Operators          []string      `yaml:"operators"`             // Emails of users who may view server-wide information such as scheduler load
+}
+
+// DatabaseConfig holds the PostgreSQL connection settings
+type DatabaseConfig struct {
+	DSN             string        `yaml:"dsn"` // Overrides the individual connection settings when set
+	Host            string        `yaml:"host"`
+	Port            string        `yaml:"port"`
+	User            string        `yaml:"user"`
+	Password        string        `yaml:"password"`
+	Name            string        `yaml:"name"`
+	SSLMode         string        `yaml:"sslmode"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+// ConnectionString returns the DSN to connect with
+func (d DatabaseConfig) ConnectionString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+	return "host=" + d.Host + " port=" + d.Port + " user=" + d.User + " password=" + d.Password + " dbname=" + d.Name + " sslmode=" + d.SSLMode
+}
+
+// CheckerConfig holds the health check settings
+type CheckerConfig struct {
+	Timeout          time.Duration `yaml:"timeout"`           // Per request
+	Retries          int           `yaml:"retries"`           // Attempts for requests that get no response
+	RetryDelay       time.Duration `yaml:"retry_delay"`       // Between attempts
+	FailureThreshold int           `yaml:"failure_threshold"` // Consecutive failed checks that open an incident
+	Workers          int           `yaml:"workers"`           // Checks running concurrently
+	QueueSize        int           `yaml:"queue_size"`        // Due checks waiting for a worker
+	MaxPerHost       int           `yaml:"max_per_host"`      // Checks running concurrently against one host, 0 for no limit
+	CertExpiryDays   int           `yaml:"cert_expiry_days"`  // Days before certificate expiry to warn, unless set per endpoint
+
+	// Flap detection over the state changes of the last FlapWindow checks, 0 disables it
+	FlapWindow         int     `yaml:"flap_window"`
+	FlapStartThreshold float64 `yaml:"flap_start_threshold"` // Percentage of state changes at which flapping starts
+	FlapStopThreshold  float64 `yaml:"flap_stop_threshold"`  // Percentage of state changes at which flapping stops
+}
+
+// SMTPConfig holds the outgoing mail server settings used by email notification channels
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// SubscriptionConfig holds the plan given to newly registered users
+type SubscriptionConfig struct {
+	PlanName         string  `yaml:"plan_name"`
+	MaxEndpoints     int     `yaml:"max_endpoints"`
+	AllowedIntervals []int64 `yaml:"allowed_intervals"` // in seconds
+	TrialDays        int     `yaml:"trial_days"`
+}
+
+// Default returns the built-in settings
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			ListenAddr: ":8080",
+		},
+		Auth: AuthConfig{
+			TokenTTL:           15 * time.Minute,
+			RefreshTokenTTL:    30 * 24 * time.Hour,
+			LoginMaxFailures:   5,
+			LoginMaxIPFailures: 20,
+			LoginLockout:       15 * time.Minute,
+		},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            "5432",
+			User:            "postgres",
+			Password:        "postgres",
+			Name:            "api_monitor",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Checker: CheckerConfig{
+			Timeout:          10 * time.Second,
+			Retries:          3,
+			RetryDelay:       2 * time.Second,
+			FailureThreshold: 3,
+			Workers:          50,
+			QueueSize:        1000,
+			MaxPerHost:       5,
+			CertExpiryDays:   14,
+
+			FlapWindow:         20,
+			FlapStartThreshold: 50,
+			FlapStopThreshold:  25,
+		},
+		SMTP: SMTPConfig{
+			Port: "25",
+			From: "api-monitor@localhost",
+		},
+		Subscription: SubscriptionConfig{
+			PlanName:         "Free",
+			MaxEndpoints:     5,
+			AllowedIntervals: []int64{5, 60, 300, 900, 1800}, // 5sec, 1min, 5min, 15min, 30min in seconds
+			TrialDays:        30,
+		},
+	}
+}
+
+// Load reads the settings from an optional YAML file, applies environment
+// overrides and validates the result. An empty path skips the file.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validSSLModes are the sslmode values understood by the PostgreSQL driver
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// Validate checks the settings and reports every problem found
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Server.ListenAddr == "" {
+		add("server.listen_addr is required")
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		add("server.tls_cert_file and server.tls_key_file must be set together")
+	}
+	if _, err := c.Server.TrustedProxyRanges(); err != nil {
+		add("server.trusted_proxies: %v", err)
+	}
+	if c.Server.BaseURL != "" {
+		if u, err := url.Parse(c.Server.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("server.base_url must be an http or https URL, got %q", c.Server.BaseURL)
+		}
+	}
+
+	switch {
+	case c.Auth.JWTSecret == "":
+		add("auth.jwt_secret is required (JWT_SECRET)")
+	case c.Auth.JWTSecret == "your-secret-key":
+		add("auth.jwt_secret must not be the example value")
+	case len(c.Auth.JWTSecret) < 32:
+		add("auth.jwt_secret must be at least 32 characters")
+	}
+	if c.Auth.TokenTTL <= 0 {
+		add("auth.token_ttl must be positive")
+	}
+	if c.Auth.RefreshTokenTTL < c.Auth.TokenTTL {
+		add("auth.refresh_token_ttl must be at least auth.token_ttl")
+	}
+	if c.Auth.LoginMaxFailures < 1 || c.Auth.LoginMaxIPFailures < 1 {
+		add("auth.login_max_failures and auth.login_max_ip_failures must be at least 1")
+	}
+	if c.Auth.LoginLockout <= 0 {
+		add("auth.login_lockout must be positive")
+	}
+
+	if c.Database.DSN == "" {
+		if c.Database.Host == "" || c.Database.Name == "" || c.Database.User == "" {
+			add("database.host, database.name and database.user are required unless database.dsn is set")
+		}
+		if !validSSLModes[c.Database.SSLMode] {
+			add("database.sslmode %q is invalid", c.Database.SSLMode)
+		}
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		add("database pool sizes must not be negative")
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		add("database.max_idle_conns must not exceed database.max_open_conns")
+	}
+
+	if c.Checker.Timeout <= 0 {
+		add("checker.timeout must be positive")
+	}
+	if c.Checker.Retries < 1 {
+		add("checker.retries must be at least 1")
+	}
+	if c.Checker.RetryDelay < 0 {
+		add("checker.retry_delay must not be negative")
+	}
+	if c.Checker.FailureThreshold < 1 {
+		add("checker.failure_threshold must be at least 1")
+	}
+	if c.Checker.Workers < 1 {
+		add("checker.workers must be at least 1")
+	}
+	if c.Checker.QueueSize < 1 {
+		add("checker.queue_size must be at least 1")
+	}
+	if c.Checker.MaxPerHost < 0 {
+		add("checker.max_per_host must not be negative")
+	}
+	if c.Checker.CertExpiryDays < 1 {
+		add("checker.cert_expiry_days must be at least 1")
+	}
+	if c.Checker.FlapWindow != 0 {
+		if c.Checker.FlapWindow < 3 {
+			add("checker.flap_window must be at least 3, or 0 to disable flap detection")
+		}
+		if c.Checker.FlapStopThreshold <= 0 || c.Checker.FlapStopThreshold >= c.Checker.FlapStartThreshold || c.Checker.FlapStartThreshold > 100 {
+			add("checker.flap_stop_threshold and checker.flap_start_threshold must satisfy 0 < stop < start <= 100")
+		}
+	}
+
+	if c.Subscription.MaxEndpoints < 0 {
+		add("subscription.max_endpoints must not be negative")
+	}
+	if len(c.Subscription.AllowedIntervals) == 0 {
+		add("subscription.allowed_intervals must not be empty")
+	}
+	for _, interval := range c.Subscription.AllowedIntervals {
+		if interval <= 0 {
+			add("subscription.allowed_intervals must be positive, got %d", interval)
+		}
+	}
+	if c.Subscription.TrialDays < 1 {
+		add("subscription.trial_days must be at least 1")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
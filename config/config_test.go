@@ -0,0 +1,46 @@
+package config
+
+import "testing"
+
+func TestTrustedProxyRanges(t *testing.T) {
+	ranges, err := ServerConfig{TrustedProxies: []string{"10.0.0.0/8", " 192.168.1.9 ", "::1"}}.TrustedProxyRanges()
+	if err != nil {
+		t.Fatalf("TrustedProxyRanges() error = %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.9/32", "::1/128"}
+	if len(ranges) != len(want) {
+		t.Fatalf("got %d ranges, want %d", len(ranges), len(want))
+	}
+	for i, r := range ranges {
+		if r.String() != want[i] {
+			t.Errorf("range %d = %s, want %s", i, r, want[i])
+		}
+	}
+
+	if _, err := (ServerConfig{TrustedProxies: []string{"proxy.local"}}).TrustedProxyRanges(); err == nil {
+		t.Error("TrustedProxyRanges() error = nil, want an error for a host name")
+	}
+}
+
+func TestValidateBaseURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		valid   bool
+	}{
+		{"", true},
+		{"https://monitor.example.com", true},
+		{"http://10.0.0.5:8080/monitor/", true},
+		{"monitor.example.com", false},
+		{"ftp://monitor.example.com", false},
+		{"https://", false},
+	}
+
+	for _, tt := range tests {
+		cfg := Default()
+		cfg.Auth.JWTSecret = "0123456789abcdef0123456789abcdef"
+		cfg.Server.BaseURL = tt.baseURL
+		if err := cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate() with base_url %q error = %v, want valid %v", tt.baseURL, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv overrides settings with the environment variables that are set
+func applyEnv(cfg *Config) error {
+	var err error
+	setString := func(key string, dst *string) {
+		if v := os.Getenv(key); v != "" {
+			*dst = v
+		}
+	}
+	setInt := func(key string, dst *int) {
+		if v := os.Getenv(key); v != "" && err == nil {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				err = fmt.Errorf("invalid %s: %q is not an integer", key, v)
+			}
+		}
+	}
+	setFloat := func(key string, dst *float64) {
+		if v := os.Getenv(key); v != "" && err == nil {
+			if *dst, err = strconv.ParseFloat(v, 64); err != nil {
+				err = fmt.Errorf("invalid %s: %q is not a number", key, v)
+			}
+		}
+	}
+	setDuration := func(key string, dst *time.Duration) {
+		if v := os.Getenv(key); v != "" && err == nil {
+			if *dst, err = time.ParseDuration(v); err != nil {
+				err = fmt.Errorf("invalid %s: %q is not a duration", key, v)
+			}
+		}
+	}
+
+	setString("LISTEN_ADDR", &cfg.Server.ListenAddr)
+	setString("TLS_CERT_FILE", &cfg.Server.TLSCertFile)
+	setString("TLS_KEY_FILE", &cfg.Server.TLSKeyFile)
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		cfg.Server.TrustedProxies = strings.Split(v, ",")
+	}
+	setString("BASE_URL", &cfg.Server.BaseURL)
+
+	setString("JWT_SECRET", &cfg.Auth.JWTSecret)
+	setDuration("JWT_TTL", &cfg.Auth.TokenTTL)
+	setDuration("REFRESH_TOKEN_TTL", &cfg.Auth.RefreshTokenTTL)
+	setInt("LOGIN_MAX_FAILURES", &cfg.Auth.LoginMaxFailures)
+	setInt("LOGIN_MAX_IP_FAILURES", &cfg.Auth.LoginMaxIPFailures)
+	setDuration("LOGIN_LOCKOUT", &cfg.Auth.LoginLockout)
+	if v := os.Getenv("OPERATORS"); v != "" {
+		cfg.Auth.Operators = strings.Split(v, ",")
+	}
+
+	setString("DB_DSN", &cfg.Database.DSN)
+	setString("DB_HOST", &cfg.Database.Host)
+	setString("DB_PORT", &cfg.Database.Port)
+	setString("DB_USER", &cfg.Database.User)
+	setString("DB_PASSWORD", &cfg.Database.Password)
+	setString("DB_NAME", &cfg.Database.Name)
+	setString("DB_SSLMODE", &cfg.Database.SSLMode)
+	setInt("DB_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns)
+	setInt("DB_MAX_IDLE_CONNS", &cfg.Database.MaxIdleConns)
+	setDuration("DB_CONN_MAX_LIFETIME", &cfg.Database.ConnMaxLifetime)
+
+	setDuration("CHECK_TIMEOUT", &cfg.Checker.Timeout)
+	setInt("CHECK_RETRIES", &cfg.Checker.Retries)
+	setDuration("CHECK_RETRY_DELAY", &cfg.Checker.RetryDelay)
+	setInt("CHECK_FAILURE_THRESHOLD", &cfg.Checker.FailureThreshold)
+	setInt("CHECK_WORKERS", &cfg.Checker.Workers)
+	setInt("CHECK_QUEUE_SIZE", &cfg.Checker.QueueSize)
+	setInt("CHECK_MAX_PER_HOST", &cfg.Checker.MaxPerHost)
+	setInt("CHECK_CERT_EXPIRY_DAYS", &cfg.Checker.CertExpiryDays)
+	setInt("CHECK_FLAP_WINDOW", &cfg.Checker.FlapWindow)
+	setFloat("CHECK_FLAP_START_THRESHOLD", &cfg.Checker.FlapStartThreshold)
+	setFloat("CHECK_FLAP_STOP_THRESHOLD", &cfg.Checker.FlapStopThreshold)
+
+	setString("SMTP_HOST", &cfg.SMTP.Host)
+	setString("SMTP_PORT", &cfg.SMTP.Port)
+	setString("SMTP_USERNAME", &cfg.SMTP.Username)
+	setString("SMTP_PASSWORD", &cfg.SMTP.Password)
+	setString("SMTP_FROM", &cfg.SMTP.From)
+
+	setString("DEFAULT_PLAN_NAME", &cfg.Subscription.PlanName)
+	setInt("DEFAULT_MAX_ENDPOINTS", &cfg.Subscription.MaxEndpoints)
+	setInt("DEFAULT_TRIAL_DAYS", &cfg.Subscription.TrialDays)
+	if v := os.Getenv("DEFAULT_ALLOWED_INTERVALS"); v != "" && err == nil {
+		var intervals []int64
+		for _, part := range strings.Split(v, ",") {
+			interval, parseErr := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if parseErr != nil {
+				return fmt.Errorf("invalid DEFAULT_ALLOWED_INTERVALS: %q is not an integer", part)
+			}
+			intervals = append(intervals, interval)
+		}
+		cfg.Subscription.AllowedIntervals = intervals
+	}
+
+	return err
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"api-monitor/models"
+	"sort"
+)
+
+// CertificateInfo is the certificate of a single endpoint
+type CertificateInfo struct {
+	EndpointID    int                 `json:"endpoint_id"`
+	URL           string              `json:"url"`
+	DaysRemaining int                 `json:"days_remaining"`
+	Certificate   *models.Certificate `json:"certificate"`
+}
+
+// GetEndpointCertificate returns the stored certificate of an endpoint, nil if none was seen yet
+func GetEndpointCertificate(endpointID int) (*models.Certificate, error) {
+	var endpoint Endpoint
+	if err := DB.Select("id", "certificate").First(&endpoint, endpointID).Error; err != nil {
+		return nil, err
+	}
+	return endpoint.Certificate, nil
+}
+
+// UpdateEndpointCertificate stores the certificate seen by the latest check of an endpoint
+func UpdateEndpointCertificate(endpointID int, cert *models.Certificate) error {
+	return DB.Model(&Endpoint{}).Where("id = ?", endpointID).Select("certificate").Updates(&Endpoint{Certificate: cert}).Error
+}
+
+// GetCertificates returns the certificates of an organization's endpoints, soonest expiry first
+func GetCertificates(orgID uint) ([]CertificateInfo, error) {
+	var endpoints []Endpoint
+	if err := DB.Select("id", "url", "certificate").Where("organization_id = ? AND certificate IS NOT NULL", orgID).Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+
+	certificates := make([]CertificateInfo, 0, len(endpoints))
+	for _, e := range endpoints {
+		if e.Certificate == nil {
+			continue
+		}
+		certificates = append(certificates, CertificateInfo{
+			EndpointID:    int(e.ID),
+			URL:           e.URL,
+			DaysRemaining: e.Certificate.DaysRemaining(),
+			Certificate:   e.Certificate,
+		})
+	}
+
+	sort.Slice(certificates, func(i, j int) bool {
+		return certificates[i].Certificate.NotAfter.Before(certificates[j].Certificate.NotAfter)
+	})
+	return certificates, nil
+}
@@ -0,0 +1,22 @@
+package database
+
+// GetActiveChannels returns the active notification channels of an organization
+func GetActiveChannels(orgID uint) ([]NotificationChannel, error) {
+	var channels []NotificationChannel
+	if err := DB.Where("organization_id = ? AND is_active = ?", orgID, true).Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// GetActiveChannelsByID returns the active notification channels of an organization among the given IDs
+func GetActiveChannelsByID(orgID uint, ids []uint) ([]NotificationChannel, error) {
+	var channels []NotificationChannel
+	if len(ids) == 0 {
+		return channels, nil
+	}
+	if err := DB.Where("organization_id = ? AND is_active = ? AND id IN ?", orgID, true, ids).Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
@@ -2,7 +2,8 @@ package database
 
 import (
 	"log"
-	"os"
+
+	"api-monitor/config"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -11,40 +12,33 @@ import (
 var DB *gorm.DB
 
 // InitDB initializes the database connection
-func InitDB() error {
-	dsn := getDSN()
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func InitDB(cfg config.DatabaseConfig) error {
+	db, err := gorm.Open(postgres.Open(cfg.ConnectionString()), &gorm.Config{})
 	if err != nil {
 		return err
 	}
 
+	// Configure the connection pool
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
 	// Auto migrate the schema
-	err = db.AutoMigrate(&User{}, &Subscription{}, &Endpoint{}, &Schedule{}, &HealthCheck{})
+	err = db.AutoMigrate(&User{}, &Organization{}, &Membership{}, &Invitation{}, &APIToken{}, &RefreshToken{}, &RevokedToken{}, &LoginThrottle{}, &FailedLogin{}, &Subscription{}, &Endpoint{}, &HealthCheck{}, &NotificationChannel{}, &Incident{}, &IncidentEvent{}, &MaintenanceWindow{}, &EscalationPolicy{}, &StatusPage{})
 	if err != nil {
 		return err
 	}
 
+	// Move data created before organizations existed into personal organizations
+	if err := migrateOrganizations(db); err != nil {
+		return err
+	}
+
 	DB = db
 	log.Println("Database connection established")
 	return nil
 }
-
-// getDSN returns the database connection string
-func getDSN() string {
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "postgres")
-	dbname := getEnv("DB_NAME", "api_monitor")
-
-	return "host=" + host + " port=" + port + " user=" + user + " password=" + password + " dbname=" + dbname + " sslmode=disable"
-}
-
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
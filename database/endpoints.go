@@ -46,16 +46,30 @@ func DeleteEndpoint(id int) error {
 	return DB.Delete(&Endpoint{}, id).Error
 }
 
-// UpdateEndpointStatus updates the status and last checked time of an endpoint
-func UpdateEndpointStatus(id int, status string) error {
+// GetEndpoint returns an endpoint by ID
+func GetEndpoint(id int) (*Endpoint, error) {
+	var endpoint Endpoint
+	if err := DB.First(&endpoint, id).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// UpdateEndpointStatus updates the status, last checked time and latency breakdown of an endpoint
+func UpdateEndpointStatus(id int, status string, timings models.Timings) error {
 	now := time.Now()
 
 	// Try up to 3 times
 	maxRetries := 3
 	for i := 0; i < maxRetries; i++ {
 		err := DB.Model(&Endpoint{}).Where("id = ?", id).Updates(map[string]interface{}{
-			"status":       status,
-			"last_checked": now,
+			"status":                  status,
+			"last_checked":            now,
+			"last_dns_lookup":         timings.DNSLookup,
+			"last_tcp_connect":        timings.TCPConnect,
+			"last_tls_handshake":      timings.TLSHandshake,
+			"last_time_to_first_byte": timings.TimeToFirstByte,
+			"last_total":              timings.Total,
 		}).Error
 
 		if err == nil {
@@ -73,3 +87,31 @@ func UpdateEndpointStatus(id int, status string) error {
 
 	return nil
 }
+
+// SetEndpointStatus updates the status of an endpoint without touching its last check
+func SetEndpointStatus(id int, status string) error {
+	return DB.Model(&Endpoint{}).Where("id = ?", id).Update("status", status).Error
+}
+
+// GetHeartbeatEndpoint returns the heartbeat endpoint with the given ping token
+func GetHeartbeatEndpoint(token string) (*Endpoint, error) {
+	var endpoint Endpoint
+	if err := DB.Where("heartbeat_token = ? AND type = ?", token, models.TypeHeartbeat).First(&endpoint).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// RecordPing stores the time a heartbeat endpoint was last pinged
+func RecordPing(id int, at time.Time) error {
+	return DB.Model(&Endpoint{}).Where("id = ?", id).Update("last_ping_at", at).Error
+}
+
+// GetLastPing returns the time a heartbeat endpoint was last pinged, nil if never
+func GetLastPing(id int) (*time.Time, error) {
+	var endpoint Endpoint
+	if err := DB.Select("id", "last_ping_at").First(&endpoint, id).Error; err != nil {
+		return nil, err
+	}
+	return endpoint.LastPingAt, nil
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GetEscalationPolicy returns an escalation policy by ID
+func GetEscalationPolicy(id uint) (*EscalationPolicy, error) {
+	var policy EscalationPolicy
+	// Deleted policies are included so that open incidents keep escalating
+	if err := DB.Unscoped().First(&policy, id).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// GetEscalatingIncidents returns the open incidents with an escalation policy
+// that may need further levels or repeated notifications. Incidents that were
+// never escalated are included, so that a first notification lost to a restart
+// is still sent, unless their endpoint is flapping and its alerts are held back.
+func GetEscalatingIncidents() ([]Incident, error) {
+	var incidents []Incident
+	err := DB.Where("status = ? AND escalation_policy_id IS NOT NULL", IncidentOpen).
+		Where(DB.Where("escalation_level > 0").
+			Or("last_escalated_at IS NULL AND NOT EXISTS (SELECT 1 FROM endpoints WHERE endpoints.id = incidents.endpoint_id AND endpoints.status = 'flapping')")).
+		Find(&incidents).Error
+	if err != nil {
+		return nil, err
+	}
+	return incidents, nil
+}
+
+// ClaimEscalation records that an incident is being notified at the given level
+// and adds message to its timeline. It returns false without changes if the
+// incident was acknowledged, resolved or escalated concurrently, so that
+// each notification is only sent once.
+func ClaimEscalation(incident *Incident, level int, at time.Time, message string) (bool, error) {
+	// Stored timestamps have microsecond precision
+	at = at.Truncate(time.Microsecond)
+
+	claimed := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&Incident{}).
+			Where("id = ? AND status = ? AND escalation_level = ?", incident.ID, IncidentOpen, incident.EscalationLevel)
+		if incident.LastEscalatedAt == nil {
+			query = query.Where("last_escalated_at IS NULL")
+		} else {
+			query = query.Where("last_escalated_at = ?", *incident.LastEscalatedAt)
+		}
+
+		result := query.Updates(map[string]interface{}{
+			"escalation_level":  level,
+			"last_escalated_at": at,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		event := IncidentEvent{
+			IncidentID: incident.ID,
+			Status:     IncidentOpen,
+			Message:    message,
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		claimed = true
+		return nil
+	})
+	if claimed {
+		incident.EscalationLevel = level
+		incident.LastEscalatedAt = &at
+	}
+	return claimed, err
+}
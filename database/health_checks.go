@@ -0,0 +1,42 @@
+package database
+
+import "time"
+
+// CreateHealthCheck stores the result of a single endpoint check
+func CreateHealthCheck(check *HealthCheck) error {
+	return DB.Create(check).Error
+}
+
+// GetHealthChecks returns a page of health checks for an endpoint, newest first,
+// together with the total number of checks in the requested time range.
+// A zero from or to leaves that side of the range open.
+func GetHealthChecks(endpointID int, from, to time.Time, limit, offset int) ([]HealthCheck, int64, error) {
+	query := DB.Model(&HealthCheck{}).Where("endpoint_id = ?", endpointID)
+	if !from.IsZero() {
+		query = query.Where("checked_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("checked_at <= ?", to)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var checks []HealthCheck
+	if err := query.Order("checked_at DESC").Limit(limit).Offset(offset).Find(&checks).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return checks, total, nil
+}
+
+// GetRecentHealthChecks returns the latest health checks of an endpoint, newest first
+func GetRecentHealthChecks(endpointID int, limit int) ([]HealthCheck, error) {
+	var checks []HealthCheck
+	if err := DB.Where("endpoint_id = ?", endpointID).Order("checked_at DESC").Limit(limit).Find(&checks).Error; err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
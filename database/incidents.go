@@ -0,0 +1,129 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrIncidentNotOpen is returned when acknowledging an incident that is not open
+var ErrIncidentNotOpen = errors.New("incident is not open")
+
+// OpenIncident creates an incident for an endpoint along with the checks that triggered it
+func OpenIncident(incident *Incident, checks []HealthCheck) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		incident.Status = IncidentOpen
+		if err := tx.Omit("Checks").Create(incident).Error; err != nil {
+			return err
+		}
+
+		event := IncidentEvent{
+			IncidentID: incident.ID,
+			Status:     IncidentOpen,
+			Message:    incident.Cause,
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+
+		if len(checks) > 0 {
+			if err := tx.Model(incident).Association("Checks").Append(checks); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetUnresolvedIncident returns the open or acknowledged incident of an endpoint, if any
+func GetUnresolvedIncident(endpointID int) (*Incident, error) {
+	var incidents []Incident
+	if err := DB.Where("endpoint_id = ? AND status <> ?", endpointID, IncidentResolved).Order("started_at DESC").Limit(1).Find(&incidents).Error; err != nil {
+		return nil, err
+	}
+	if len(incidents) == 0 {
+		return nil, nil
+	}
+	return &incidents[0], nil
+}
+
+// ResolveIncident marks an incident as resolved by the given recovery check
+func ResolveIncident(id uint, check *HealthCheck) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var incident Incident
+		if err := tx.First(&incident, id).Error; err != nil {
+			return err
+		}
+		if incident.Status == IncidentResolved {
+			return nil
+		}
+
+		now := check.CheckedAt
+		updates := map[string]interface{}{
+			"status":      IncidentResolved,
+			"resolved_at": now,
+			"duration":    int64(now.Sub(incident.StartedAt) / time.Second),
+		}
+		if err := tx.Model(&incident).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		event := IncidentEvent{
+			IncidentID: incident.ID,
+			Status:     IncidentResolved,
+			Message:    "Endpoint recovered",
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&incident).Association("Checks").Append(check)
+	})
+}
+
+// AcknowledgeIncident records that a user is handling an open incident. The
+// status is checked in the update itself, so that an incident resolved
+// concurrently is not reopened as acknowledged.
+func AcknowledgeIncident(incident *Incident, userID uint, note string) error {
+	if incident.Status != IncidentOpen {
+		return ErrIncidentNotOpen
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":          IncidentAcknowledged,
+			"acknowledged_at": now,
+			"acknowledged_by": userID,
+		}
+		result := tx.Model(incident).Where("status = ?", IncidentOpen).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrIncidentNotOpen
+		}
+
+		message := "Incident acknowledged"
+		if note != "" {
+			message += ": " + note
+		}
+		event := IncidentEvent{
+			IncidentID: incident.ID,
+			Status:     IncidentAcknowledged,
+			Message:    message,
+			UserID:     &userID,
+		}
+		return tx.Create(&event).Error
+	})
+}
+
+// GetIncident returns an incident by ID
+func GetIncident(id uint) (*Incident, error) {
+	var incident Incident
+	if err := DB.First(&incident, id).Error; err != nil {
+		return nil, err
+	}
+	return &incident, nil
+}
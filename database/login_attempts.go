@@ -0,0 +1,71 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecordFailedLogin adds a failed login to the audit trail
+func RecordFailedLogin(failure *FailedLogin) error {
+	return DB.Create(failure).Error
+}
+
+// CountAttempt counts an attempt made at the given time against the given keys
+// and returns their counters as they were before it. Incrementing and reading
+// back happen in one statement, so that concurrent attempts each see the ones
+// counted before them. Counters whose last attempt is older than window start
+// over.
+func CountAttempt(keys []string, at time.Time, window time.Duration) ([]LoginThrottle, error) {
+	throttles := make([]LoginThrottle, len(keys))
+	for i, key := range keys {
+		throttles[i] = LoginThrottle{Key: key, Failures: 1, LastFailureAt: at}
+	}
+
+	err := DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failures":            gorm.Expr("CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END", at.Add(-window)),
+			"last_failure_at":     at,
+			"previous_failure_at": gorm.Expr("login_throttles.last_failure_at"),
+		}),
+	}, clause.Returning{}).Create(&throttles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range throttles {
+		throttles[i].Failures--
+		throttles[i].LastFailureAt = throttles[i].PreviousFailureAt
+	}
+	return throttles, nil
+}
+
+// UncountAttempt takes back an attempt counted against a key
+func UncountAttempt(key string) error {
+	return DB.Model(&LoginThrottle{}).Where("key = ? AND failures > 0", key).Update("failures", gorm.Expr("failures - 1")).Error
+}
+
+// ResetLoginFailures clears the failure counter of a key
+func ResetLoginFailures(key string) error {
+	return DB.Where("key = ?", key).Delete(&LoginThrottle{}).Error
+}
+
+// GetFailedLogins returns the most recent failed logins of a user, newest first
+func GetFailedLogins(userID uint, limit int) ([]FailedLogin, error) {
+	var failures []FailedLogin
+	if err := DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&failures).Error; err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+// DeleteStaleLoginRecords removes failure counters last updated before
+// throttleBefore and audit trail entries created before auditBefore
+func DeleteStaleLoginRecords(throttleBefore, auditBefore time.Time) error {
+	if err := DB.Where("last_failure_at < ?", throttleBefore).Delete(&LoginThrottle{}).Error; err != nil {
+		return err
+	}
+	return DB.Where("created_at < ?", auditBefore).Delete(&FailedLogin{}).Error
+}
@@ -0,0 +1,10 @@
+package database
+
+// GetActiveMaintenanceWindows returns the active maintenance windows of all users
+func GetActiveMaintenanceWindows() ([]MaintenanceWindow, error) {
+	var windows []MaintenanceWindow
+	if err := DB.Where("is_active = ?", true).Find(&windows).Error; err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
@@ -18,6 +18,85 @@ const (
 	Interval1Hour = 3600
 )
 
+// Notification channel types
+const (
+	ChannelTypeWebhook = "webhook"
+	ChannelTypeEmail   = "email"
+	ChannelTypeSlack   = "slack"
+)
+
+// Maintenance window modes
+const (
+	MaintenanceSkip   = "skip"   // Checks do not run
+	MaintenanceRecord = "record" // Checks run and are recorded as maintenance
+)
+
+// Incident statuses
+const (
+	IncidentOpen         = "open"
+	IncidentAcknowledged = "acknowledged"
+	IncidentResolved     = "resolved"
+)
+
+// Organization member roles, from most to least privileged
+const (
+	RoleOwner  = "owner"  // Manages members, owners and the organization itself
+	RoleAdmin  = "admin"  // Manages members and invitations
+	RoleEditor = "editor" // Manages endpoints, channels and other resources
+	RoleViewer = "viewer" // Reads resources
+)
+
+// roleRanks orders the roles by privilege
+var roleRanks = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// ValidRole reports whether role is a known member role
+func ValidRole(role string) bool {
+	return roleRanks[role] > 0
+}
+
+// RoleAtLeast reports whether role grants at least the privileges of min
+func RoleAtLeast(role, min string) bool {
+	return ValidRole(role) && roleRanks[role] >= roleRanks[min]
+}
+
+// API token scopes. Every scope allows reading the resources of the
+// organizations the token's user belongs to.
+const (
+	ScopeRead             = "read"
+	ScopeEndpointsWrite   = "endpoints:write"
+	ScopeChannelsWrite    = "channels:write"
+	ScopeIncidentsWrite   = "incidents:write"
+	ScopeMaintenanceWrite = "maintenance:write"
+	ScopeEscalationWrite  = "escalation:write"
+	ScopeStatusPagesWrite = "status_pages:write"
+)
+
+// Scopes lists the valid API token scopes
+var Scopes = []string{
+	ScopeRead,
+	ScopeEndpointsWrite,
+	ScopeChannelsWrite,
+	ScopeIncidentsWrite,
+	ScopeMaintenanceWrite,
+	ScopeEscalationWrite,
+	ScopeStatusPagesWrite,
+}
+
+// ValidScope reports whether scope is a known API token scope
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // User represents a system user
 type User struct {
 	gorm.Model
@@ -28,10 +107,115 @@ type User struct {
 	Endpoints []Endpoint `json:"endpoints" gorm:"foreignKey:UserID"`
 }
 
-// Subscription represents a user's subscription plan
+// Organization owns endpoints, channels and other resources shared by its members.
+// Every user has a personal organization created at registration.
+type Organization struct {
+	gorm.Model
+	Name     string `json:"name"`
+	Personal bool   `json:"personal"` // Created for a single user, cannot be deleted
+}
+
+// Membership grants a user a role in an organization
+type Membership struct {
+	gorm.Model
+	OrganizationID uint   `json:"organization_id" gorm:"uniqueIndex:idx_memberships_organization_user"`
+	UserID         uint   `json:"user_id" gorm:"uniqueIndex:idx_memberships_organization_user;index"`
+	Role           string `json:"role"`
+}
+
+// Invitation lets the holder of a token sent by email join an organization.
+// Only a hash of the token is stored.
+type Invitation struct {
+	gorm.Model
+	OrganizationID uint       `json:"organization_id" gorm:"index"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	TokenHash      string     `json:"-" gorm:"uniqueIndex"`
+	InvitedBy      uint       `json:"invited_by"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at"`
+}
+
+// APIToken is a long-lived personal credential for automation. It acts as its
+// user, limited to its scopes. Only a hash of the token is stored; revoked
+// tokens are soft deleted.
+type APIToken struct {
+	gorm.Model
+	UserID     uint           `json:"user_id" gorm:"index"`
+	Name       string         `json:"name"`
+	Prefix     string         `json:"prefix"` // Start of the token, to tell tokens apart
+	TokenHash  string         `json:"-" gorm:"uniqueIndex"`
+	Scopes     pq.StringArray `json:"scopes" gorm:"type:text[]"`
+	ExpiresAt  *time.Time     `json:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	LastUsedIP string         `json:"last_used_ip"`
+}
+
+// HasScope reports whether the token was granted scope
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshToken is a single-use credential for getting a new access token. Each
+// refresh replaces it with a new one in the same family; a family is one sign-in
+// session. Only a hash of the token is stored.
+type RefreshToken struct {
+	gorm.Model
+	UserID          uint       `gorm:"index"`
+	FamilyID        string     `gorm:"index"`
+	TokenHash       string     `gorm:"uniqueIndex"`
+	AccessTokenID   string     // jti of the access token issued along with this refresh token
+	AccessExpiresAt time.Time  // When that access token expires
+	ExpiresAt       time.Time  // When this refresh token expires if unused
+	UsedAt          *time.Time // Set when exchanged for a new token pair
+	RevokedAt       *time.Time // Set when the session was logged out or its family was revoked
+}
+
+// RevokedToken is an access token that was revoked before it expired. Entries
+// can be removed once the token has expired.
+type RevokedToken struct {
+	TokenID   string    `gorm:"primaryKey"` // jti of the access token
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+// Reasons a login failed
+const (
+	LoginFailureUnknownEmail  = "unknown_email"
+	LoginFailureWrongPassword = "wrong_password"
+	LoginFailureInactive      = "inactive"  // Right password for an inactive account
+	LoginFailureThrottled     = "throttled" // Rejected during backoff or lockout, without checking the password
+)
+
+// LoginThrottle counts the recent login attempts of an account or an IP address
+// that did not succeed. Attempts are counted before the password is checked.
+type LoginThrottle struct {
+	Key               string    `gorm:"primaryKey"` // "account:<email>" or "ip:<address>"
+	Failures          int       // Consecutive failures, restarting once the last one is older than the lockout
+	LastFailureAt     time.Time `gorm:"index"`
+	PreviousFailureAt time.Time // When the failure before the last one was, to throttle the last one against
+}
+
+// FailedLogin is an entry in the audit trail of failed logins
+type FailedLogin struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	Email     string    `json:"email" gorm:"index"`
+	UserID    *uint     `json:"user_id" gorm:"index"` // Set when the email belongs to a user
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Reason    string    `json:"reason"`
+}
+
+// Subscription represents an organization's subscription plan
 type Subscription struct {
 	gorm.Model
-	UserID           uint          `json:"user_id"`
+	OrganizationID   uint          `json:"organization_id" gorm:"index"`
+	UserID           uint          `json:"user_id"` // User who signed up for the plan
 	PlanName         string        `json:"plan_name"`
 	MaxEndpoints     int           `json:"max_endpoints"`
 	AllowedIntervals pq.Int64Array `json:"allowed_intervals" gorm:"type:integer[]"`
@@ -42,51 +226,316 @@ type Subscription struct {
 // Endpoint represents an API endpoint to monitor
 type Endpoint struct {
 	gorm.Model
-	UserID      uint      `json:"user_id"`
+	OrganizationID uint `json:"organization_id" gorm:"index"`
+	UserID         uint `json:"user_id"` // User who created the endpoint
+
+	Type        string    `json:"type" gorm:"default:http"`
 	URL         string    `json:"url"`
 	Interval    int       `json:"interval"` // in seconds
 	LastChecked time.Time `json:"last_checked"`
 	Status      string    `json:"status"`
 	ExpiresAt   time.Time `json:"expires_at"` // When the endpoint expires
+
+	LastTimings models.Timings `json:"last_timings" gorm:"embedded;embeddedPrefix:last_"`
+
+	// HTTP probe settings
+	Method         string            `json:"method"`
+	Headers        map[string]string `json:"headers" gorm:"type:jsonb;serializer:json"`
+	Body           string            `json:"body"`
+	ExpectedStatus pq.Int64Array     `json:"expected_status" gorm:"type:integer[]"`
+
+	Assertions []models.Assertion `json:"assertions" gorm:"type:jsonb;serializer:json"`
+
+	// TCP probe settings
+	Send   string `json:"send"`
+	Expect string `json:"expect"`
+
+	// DNS probe settings
+	RecordType     string         `json:"record_type"`
+	Resolver       string         `json:"resolver"`
+	ExpectedValues pq.StringArray `json:"expected_values" gorm:"type:text[]"`
+
+	// gRPC probe settings
+	GRPCService string `json:"grpc_service"`
+	GRPCTLS     bool   `json:"grpc_tls"`
+
+	// Transaction settings
+	Steps []models.TransactionStep `json:"steps" gorm:"type:jsonb;serializer:json"`
+
+	// Heartbeat settings
+	HeartbeatToken string     `json:"heartbeat_token" gorm:"index"`
+	GracePeriod    int        `json:"grace_period"`
+	LastPingAt     *time.Time `json:"last_ping_at"`
+
+	Tags pq.StringArray `json:"tags" gorm:"type:text[]"` // Used to match maintenance windows
+
+	EscalationPolicyID *uint `json:"escalation_policy_id" gorm:"index"`
+
+	// TLS certificate monitoring
+	CertExpiryDays int                 `json:"cert_expiry_days"`
+	Certificate    *models.Certificate `json:"certificate" gorm:"type:jsonb;serializer:json"`
+
+	SLATarget float64 `json:"sla_target"` // Uptime percentage, DefaultSLATarget if zero
 }
 
 // ToModel converts a database Endpoint to a models.Endpoint
 func (e *Endpoint) ToModel() models.Endpoint {
 	return models.Endpoint{
-		ID:          int(e.ID),
+		ID:             int(e.ID),
+		OrganizationID: e.OrganizationID,
+		UserID:         e.UserID,
+
+		Type:        e.Type,
 		URL:         e.URL,
 		Interval:    e.Interval,
 		LastChecked: e.LastChecked,
 		Status:      e.Status,
 		ExpiresAt:   e.ExpiresAt,
+		LastTimings: e.LastTimings,
+
+		Method:         e.Method,
+		Headers:        e.Headers,
+		Body:           e.Body,
+		ExpectedStatus: e.ExpectedStatus,
+
+		Assertions: e.Assertions,
+
+		Send:   e.Send,
+		Expect: e.Expect,
+
+		RecordType:     e.RecordType,
+		Resolver:       e.Resolver,
+		ExpectedValues: e.ExpectedValues,
+
+		GRPCService: e.GRPCService,
+		GRPCTLS:     e.GRPCTLS,
+
+		Steps: e.Steps,
+
+		HeartbeatToken: e.HeartbeatToken,
+		GracePeriod:    e.GracePeriod,
+		LastPingAt:     e.LastPingAt,
+
+		Tags: e.Tags,
+
+		EscalationPolicyID: e.EscalationPolicyID,
+
+		CertExpiryDays: e.CertExpiryDays,
+		Certificate:    e.Certificate,
+
+		SLATarget: e.SLATarget,
 	}
 }
 
 // FromModel creates a database Endpoint from a models.Endpoint
 func FromModel(e models.Endpoint) Endpoint {
 	return Endpoint{
+		Type:        e.Type,
 		URL:         e.URL,
 		Interval:    e.Interval,
 		LastChecked: e.LastChecked,
 		Status:      e.Status,
 		ExpiresAt:   e.ExpiresAt,
+
+		Method:         e.Method,
+		Headers:        e.Headers,
+		Body:           e.Body,
+		ExpectedStatus: e.ExpectedStatus,
+
+		Assertions: e.Assertions,
+
+		Send:   e.Send,
+		Expect: e.Expect,
+
+		RecordType:     e.RecordType,
+		Resolver:       e.Resolver,
+		ExpectedValues: e.ExpectedValues,
+
+		GRPCService: e.GRPCService,
+		GRPCTLS:     e.GRPCTLS,
+
+		Steps: e.Steps,
+
+		GracePeriod: e.GracePeriod,
+
+		Tags: e.Tags,
+
+		EscalationPolicyID: e.EscalationPolicyID,
+
+		CertExpiryDays: e.CertExpiryDays,
+
+		SLATarget: e.SLATarget,
 	}
 }
 
-// Schedule represents a monitoring schedule
-type Schedule struct {
+// HealthCheck represents a health check result
+type HealthCheck struct {
 	gorm.Model
-	Name      string        `json:"name"`
-	Interval  int           `json:"interval"` // in seconds
-	CreatedAt time.Time     `json:"created_at"`
-	Endpoints pq.Int64Array `json:"endpoints" gorm:"type:integer[]"` // List of endpoint IDs
+	EndpointID      int       `json:"endpoint_id" gorm:"index:idx_health_checks_endpoint_checked_at"`
+	Status          int       `json:"status"`        // HTTP status code, 0 if no response was received
+	Result          string    `json:"result"`        // "ok", "error", "skipped" or "maintenance"
+	ResponseTime    int64     `json:"response_time"` // in milliseconds, the total of the latency breakdown
+	Error           string    `json:"error"`
+	FailedAssertion string    `json:"failed_assertion"` // The assertion that failed, if any
+	Response        string    `json:"response"`         // Response body, truncated
+	CheckedAt       time.Time `json:"checked_at" gorm:"index:idx_health_checks_endpoint_checked_at"`
+
+	// Latency breakdown, in milliseconds
+	DNSLookup       int64 `json:"dns_lookup"`
+	TCPConnect      int64 `json:"tcp_connect"`
+	TLSHandshake    int64 `json:"tls_handshake"`
+	TimeToFirstByte int64 `json:"time_to_first_byte"`
+
+	Steps []models.StepResult `json:"steps,omitempty" gorm:"type:jsonb;serializer:json"` // Per-step results of a transaction
+
+	Certificate *models.Certificate `json:"-" gorm:"-"` // Certificate presented during the check, stored on the endpoint
 }
 
-// HealthCheck represents a health check result
-type HealthCheck struct {
+// NotificationChannel represents a destination for an organization's alert notifications
+type NotificationChannel struct {
+	gorm.Model
+	OrganizationID uint `json:"organization_id" gorm:"index"`
+	UserID         uint `json:"user_id" gorm:"index"` // User who created the channel
+
+	Name     string `json:"name"`
+	Type     string `json:"type"`   // webhook, email or slack
+	Target   string `json:"target"` // URL for webhook and slack, comma-separated addresses for email
+	IsActive bool   `json:"is_active" gorm:"default:true"`
+}
+
+// Incident represents an outage of an endpoint, from crossing the failure threshold until recovery
+type Incident struct {
+	gorm.Model
+	EndpointID     int             `json:"endpoint_id" gorm:"index"`
+	OrganizationID uint            `json:"organization_id" gorm:"index"`
+	UserID         uint            `json:"user_id" gorm:"index"`
+	Status         string          `json:"status"` // open, acknowledged or resolved
+	Cause          string          `json:"cause"`
+	StartedAt      time.Time       `json:"started_at"`
+	AcknowledgedAt *time.Time      `json:"acknowledged_at"`
+	AcknowledgedBy *uint           `json:"acknowledged_by"`
+	ResolvedAt     *time.Time      `json:"resolved_at"`
+	Duration       int64           `json:"duration"` // in seconds, set once resolved
+	Events         []IncidentEvent `json:"events,omitempty"`
+	Checks         []HealthCheck   `json:"checks,omitempty" gorm:"many2many:incident_checks"`
+
+	// Escalation state, the policy is copied from the endpoint when the incident opens
+	EscalationPolicyID *uint      `json:"escalation_policy_id"`
+	EscalationLevel    int        `json:"escalation_level"`  // Highest policy level notified, 0 if none
+	LastEscalatedAt    *time.Time `json:"last_escalated_at"` // When a level was last notified
+}
+
+// IncidentEvent is an entry in the timeline of an incident
+type IncidentEvent struct {
 	gorm.Model
-	EndpointID int       `json:"endpoint_id"`
-	Status     int       `json:"status"`
-	Response   string    `json:"response"`
-	CheckedAt  time.Time `json:"checked_at"`
+	IncidentID uint   `json:"incident_id" gorm:"index"`
+	Status     string `json:"status"` // Incident status after the event
+	Message    string `json:"message"`
+	UserID     *uint  `json:"user_id"` // Set when the change was made by a user
+}
+
+// MaintenanceWindow is a period during which the matching endpoints of an
+// organization are not checked or their failures are ignored. It is either
+// one-off, from StartsAt to EndsAt, or recurring, starting at the times of a
+// cron expression.
+type MaintenanceWindow struct {
+	gorm.Model
+	OrganizationID uint `json:"organization_id" gorm:"index"`
+	UserID         uint `json:"user_id" gorm:"index"` // User who created the window
+
+	Name        string         `json:"name"`
+	EndpointIDs pq.Int64Array  `json:"endpoint_ids" gorm:"type:integer[]"`
+	Tags        pq.StringArray `json:"tags" gorm:"type:text[]"` // Endpoints with any of these tags are matched too
+	StartsAt    *time.Time     `json:"starts_at"`
+	EndsAt      *time.Time     `json:"ends_at"`
+	Cron        string         `json:"cron"`      // Start times of a recurring window
+	Duration    int            `json:"duration"`  // Length of a recurring window in minutes
+	TimeZone    string         `json:"time_zone"` // Time zone the cron expression is evaluated in, UTC if empty
+	Mode        string         `json:"mode"`      // skip or record
+	IsActive    bool           `json:"is_active" gorm:"default:true"`
+}
+
+// EscalationPolicy decides who is notified of an incident and when. Its first
+// level is notified as soon as the incident opens, later levels once their delay
+// has passed without the incident being acknowledged.
+type EscalationPolicy struct {
+	gorm.Model
+	OrganizationID uint              `json:"organization_id" gorm:"index"`
+	UserID         uint              `json:"user_id" gorm:"index"` // User who created the policy
+	Name           string            `json:"name"`
+	Levels         []EscalationLevel `json:"levels" gorm:"type:jsonb;serializer:json"`
+}
+
+// EscalationLevel is a step of an escalation policy
+type EscalationLevel struct {
+	Delay          int    `json:"delay"`           // Minutes after the incident opened, 0 for the first level
+	ChannelIDs     []uint `json:"channel_ids"`     // Notification channels to notify
+	RepeatInterval int    `json:"repeat_interval"` // Minutes between repeated notifications while this is the last level reached, 0 to notify once
+}
+
+// DueLevel returns the highest level whose delay has passed at the given time, 1-based, 0 if none
+func (p *EscalationPolicy) DueLevel(startedAt, at time.Time) int {
+	level := 0
+	for i, l := range p.Levels {
+		if at.Sub(startedAt) >= time.Duration(l.Delay)*time.Minute {
+			level = i + 1
+		}
+	}
+	return level
+}
+
+// StatusPage is a public page showing the state and uptime of selected endpoints
+// of an organization, grouped into components, at /status/:slug
+type StatusPage struct {
+	gorm.Model
+	OrganizationID uint `json:"organization_id" gorm:"index"`
+	UserID         uint `json:"user_id" gorm:"index"` // User who created the page
+
+	Slug        string            `json:"slug" gorm:"uniqueIndex"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Components  []StatusComponent `json:"components" gorm:"type:jsonb;serializer:json"`
+}
+
+// StatusComponent is a named group of endpoints on a status page
+type StatusComponent struct {
+	Name      string               `json:"name"`
+	Endpoints []StatusPageEndpoint `json:"endpoints"`
+}
+
+// StatusPageEndpoint is an endpoint shown on a status page under a public display name
+type StatusPageEndpoint struct {
+	EndpointID  int    `json:"endpoint_id"`
+	DisplayName string `json:"display_name"`
+}
+
+// EndpointIDs returns the IDs of all endpoints shown on the page
+func (p *StatusPage) EndpointIDs() []int {
+	var ids []int
+	for _, component := range p.Components {
+		for _, e := range component.Endpoints {
+			ids = append(ids, e.EndpointID)
+		}
+	}
+	return ids
+}
+
+// Timings returns the latency breakdown of the check
+func (h *HealthCheck) Timings() models.Timings {
+	return models.Timings{
+		DNSLookup:       h.DNSLookup,
+		TCPConnect:      h.TCPConnect,
+		TLSHandshake:    h.TLSHandshake,
+		TimeToFirstByte: h.TimeToFirstByte,
+		Total:           h.ResponseTime,
+	}
+}
+
+// SetTimings stores a latency breakdown on the check
+func (h *HealthCheck) SetTimings(t models.Timings) {
+	h.DNSLookup = t.DNSLookup
+	h.TCPConnect = t.TCPConnect
+	h.TLSHandshake = t.TLSHandshake
+	h.TimeToFirstByte = t.TimeToFirstByte
+	h.ResponseTime = t.Total
 }
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvitationUsed is returned when accepting an invitation that was already accepted
+var ErrInvitationUsed = errors.New("invitation was already accepted")
+
+// organizationTables are the tables whose rows belong to an organization
+var organizationTables = []string{
+	"subscriptions",
+	"endpoints",
+	"notification_channels",
+	"incidents",
+	"maintenance_windows",
+	"escalation_policies",
+	"status_pages",
+}
+
+// CreatePersonalOrganization creates the personal organization of a user with the user as its owner
+func CreatePersonalOrganization(tx *gorm.DB, user *User) (*Organization, error) {
+	name := user.Name
+	if name == "" {
+		name = user.Email
+	}
+	return CreateOrganization(tx, name, true, user.ID)
+}
+
+// CreateOrganization creates an organization with ownerID as its owner
+func CreateOrganization(tx *gorm.DB, name string, personal bool, ownerID uint) (*Organization, error) {
+	org := &Organization{Name: name, Personal: personal}
+	if err := tx.Create(org).Error; err != nil {
+		return nil, err
+	}
+
+	membership := &Membership{OrganizationID: org.ID, UserID: ownerID, Role: RoleOwner}
+	if err := tx.Create(membership).Error; err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// GetMembership returns the membership of a user in an organization
+func GetMembership(orgID, userID uint) (*Membership, error) {
+	var membership Membership
+	if err := DB.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&membership).Error; err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// GetPersonalMembership returns the membership of a user in their personal organization
+func GetPersonalMembership(userID uint) (*Membership, error) {
+	var membership Membership
+	err := DB.Joins("JOIN organizations ON organizations.id = memberships.organization_id AND organizations.deleted_at IS NULL").
+		Where("memberships.user_id = ? AND organizations.personal = ?", userID, true).
+		Order("memberships.id").
+		First(&membership).Error
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// RemoveMembership removes a user from an organization. Memberships are removed
+// for good so that the user can be invited again.
+func RemoveMembership(membership *Membership) error {
+	return DB.Unscoped().Delete(membership).Error
+}
+
+// CountOwners returns the number of owners of an organization
+func CountOwners(orgID uint) (int64, error) {
+	var count int64
+	err := DB.Model(&Membership{}).Where("organization_id = ? AND role = ?", orgID, RoleOwner).Count(&count).Error
+	return count, err
+}
+
+// migrateOrganizations gives every user without one a personal organization and
+// moves the resources created before organizations existed into the personal
+// organization of their creator
+func migrateOrganizations(db *gorm.DB) error {
+	var users []User
+	err := db.Where("NOT EXISTS (SELECT 1 FROM memberships WHERE memberships.user_id = users.id AND memberships.deleted_at IS NULL)").
+		Find(&users).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range users {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			_, err := CreatePersonalOrganization(tx, &users[i])
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(users) > 0 {
+		log.Printf("Created personal organizations for %d users", len(users))
+	}
+
+	for _, table := range organizationTables {
+		query := fmt.Sprintf(`UPDATE %[1]s SET organization_id = organizations.id
+			FROM memberships JOIN organizations ON organizations.id = memberships.organization_id
+			WHERE memberships.user_id = %[1]s.user_id AND organizations.personal
+			AND (%[1]s.organization_id IS NULL OR %[1]s.organization_id = 0)`, table)
+		if err := db.Exec(query).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Member is a user with their role in an organization
+type Member struct {
+	UserID   uint      `json:"user_id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// GetMembers returns the members of an organization in the order they joined
+func GetMembers(orgID uint) ([]Member, error) {
+	var members []Member
+	err := DB.Model(&Membership{}).
+		Select("memberships.user_id, users.name, users.email, memberships.role, memberships.created_at AS joined_at").
+		Joins("JOIN users ON users.id = memberships.user_id").
+		Where("memberships.organization_id = ?", orgID).
+		Order("memberships.id").
+		Scan(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// DeleteOrganization removes an organization along with its memberships, invitations and subscription
+func DeleteOrganization(org *Organization) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// Memberships are removed for good so that they do not block rejoining
+		if err := tx.Unscoped().Where("organization_id = ?", org.ID).Delete(&Membership{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&Invitation{}, &Subscription{}} {
+			if err := tx.Where("organization_id = ?", org.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(org).Error
+	})
+}
+
+// AcceptInvitation makes a user a member of the organization of an invitation.
+// It returns ErrInvitationUsed if the invitation was accepted concurrently.
+func AcceptInvitation(invitation *Invitation, userID uint, at time.Time) (*Membership, error) {
+	membership := &Membership{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         userID,
+		Role:           invitation.Role,
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(invitation).Where("accepted_at IS NULL").Update("accepted_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationUsed
+		}
+		return tx.Create(membership).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRefreshTokenReused is returned when rotating a refresh token that was
+// already exchanged, which means that it leaked or was replayed
+var ErrRefreshTokenReused = errors.New("refresh token was already used")
+
+// GetRefreshTokenByHash returns the refresh token with the given hash
+func GetRefreshTokenByHash(hash string) (*RefreshToken, error) {
+	var token RefreshToken
+	if err := DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// CreateRefreshToken stores the first refresh token of a session
+func CreateRefreshToken(token *RefreshToken) error {
+	return DB.Create(token).Error
+}
+
+// RotateRefreshToken marks a refresh token as used and stores its replacement
+// in the same family. It returns ErrRefreshTokenReused without changes if the
+// token was used or revoked concurrently.
+func RotateRefreshToken(current, next *RefreshToken, at time.Time) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(current).Where("used_at IS NULL AND revoked_at IS NULL").Update("used_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		next.UserID = current.UserID
+		next.FamilyID = current.FamilyID
+		return tx.Create(next).Error
+	})
+}
+
+// RevokeSession ends a sign-in session: its refresh tokens stop working and
+// the access tokens issued with them are added to the revocation list
+func RevokeSession(familyID string, at time.Time) error {
+	return revokeRefreshTokens("family_id = ?", familyID, at)
+}
+
+// RevokeUserSessions ends every sign-in session of a user
+func RevokeUserSessions(userID uint, at time.Time) error {
+	return revokeRefreshTokens("user_id = ?", userID, at)
+}
+
+// revokeRefreshTokens revokes the refresh tokens matched by a condition along
+// with their access tokens that have not expired yet
+func revokeRefreshTokens(condition string, arg interface{}, at time.Time) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var tokens []RefreshToken
+		if err := tx.Where(condition, arg).Where("access_expires_at > ?", at).Find(&tokens).Error; err != nil {
+			return err
+		}
+
+		revoked := make([]RevokedToken, 0, len(tokens))
+		for _, token := range tokens {
+			if token.AccessTokenID != "" {
+				revoked = append(revoked, RevokedToken{TokenID: token.AccessTokenID, ExpiresAt: token.AccessExpiresAt})
+			}
+		}
+		if len(revoked) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&RefreshToken{}).Where(condition, arg).Where("revoked_at IS NULL").Update("revoked_at", at).Error
+	})
+}
+
+// IsTokenRevoked reports whether the access token with the given jti was revoked
+func IsTokenRevoked(tokenID string) (bool, error) {
+	var count int64
+	if err := DB.Model(&RevokedToken{}).Where("token_id = ?", tokenID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// DeleteExpiredSessions removes refresh tokens and revocation list entries
+// that expired before at, as they are rejected anyway
+func DeleteExpiredSessions(at time.Time) error {
+	if err := DB.Where("expires_at < ?", at).Delete(&RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return DB.Unscoped().Where("expires_at < ?", at).Delete(&RefreshToken{}).Error
+}
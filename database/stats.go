@@ -0,0 +1,163 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DefaultSLATarget is the uptime percentage used when an endpoint has no SLA target configured
+const DefaultSLATarget = 99.9
+
+// EndpointStats summarises the checks and incidents of an endpoint over a time range
+type EndpointStats struct {
+	EndpointID   int       `json:"endpoint_id"`
+	URL          string    `json:"url"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	TotalChecks  int64     `json:"total_checks"` // Checks that ran, skipped and maintenance checks are not counted
+	FailedChecks int64     `json:"failed_checks"`
+	Uptime       float64   `json:"uptime"`      // Percentage of successful checks, 100 if there were none
+	LatencyP50   float64   `json:"latency_p50"` // in milliseconds, over successful checks
+	LatencyP95   float64   `json:"latency_p95"` // in milliseconds, over successful checks
+	LatencyP99   float64   `json:"latency_p99"` // in milliseconds, over successful checks
+	Incidents    int64     `json:"incidents"`   // Incidents overlapping the range
+	Downtime     int64     `json:"downtime"`    // in seconds, incident time within the range
+	MTTR         int64     `json:"mttr"`        // Mean time to resolve in seconds, over incidents resolved within the range
+	SLATarget    float64   `json:"sla_target"`
+	SLABreached  bool      `json:"sla_breached"`
+}
+
+// GetEndpointStats computes the uptime, latency and incident statistics of an
+// endpoint between from and to. A zero slaTarget uses the endpoint's own target.
+func GetEndpointStats(endpoint *Endpoint, from, to time.Time, slaTarget float64) (*EndpointStats, error) {
+	stats, err := GetEndpointsStats([]Endpoint{*endpoint}, from, to, slaTarget)
+	if err != nil {
+		return nil, err
+	}
+	return stats[0], nil
+}
+
+// GetEndpointsStats computes the statistics of several endpoints, in the same
+// order, with one query grouped by endpoint per table
+func GetEndpointsStats(endpoints []Endpoint, from, to time.Time, slaTarget float64) ([]*EndpointStats, error) {
+	result := make([]*EndpointStats, len(endpoints))
+	byID := make(map[int]*EndpointStats, len(endpoints))
+	ids := make([]int, len(endpoints))
+	for i := range endpoints {
+		stats := &EndpointStats{
+			EndpointID: int(endpoints[i].ID),
+			URL:        endpoints[i].URL,
+			From:       from,
+			To:         to,
+			Uptime:     100,
+			SLATarget:  slaTarget,
+		}
+		if stats.SLATarget == 0 {
+			stats.SLATarget = endpoints[i].SLATarget
+		}
+		if stats.SLATarget == 0 {
+			stats.SLATarget = DefaultSLATarget
+		}
+		result[i] = stats
+		byID[stats.EndpointID] = stats
+		ids[i] = stats.EndpointID
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var checks []EndpointStats
+	err := DB.Model(&HealthCheck{}).
+		Select(`endpoint_id,
+			COUNT(*) AS total_checks,
+			COUNT(*) FILTER (WHERE result <> 'ok') AS failed_checks,
+			COALESCE(percentile_cont(0.50) WITHIN GROUP (ORDER BY response_time) FILTER (WHERE result = 'ok'), 0) AS latency_p50,
+			COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY response_time) FILTER (WHERE result = 'ok'), 0) AS latency_p95,
+			COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY response_time) FILTER (WHERE result = 'ok'), 0) AS latency_p99`).
+		Where("endpoint_id IN ? AND checked_at >= ? AND checked_at <= ? AND result NOT IN ('skipped', 'maintenance')", ids, from, to).
+		Group("endpoint_id").
+		Scan(&checks).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range checks {
+		stats := byID[row.EndpointID]
+		stats.TotalChecks = row.TotalChecks
+		stats.FailedChecks = row.FailedChecks
+		stats.LatencyP50 = row.LatencyP50
+		stats.LatencyP95 = row.LatencyP95
+		stats.LatencyP99 = row.LatencyP99
+	}
+
+	// Ongoing incidents count as down until now
+	end := to
+	if now := time.Now(); now.Before(end) {
+		end = now
+	}
+
+	// Incidents overlapping the range count towards downtime, those resolved
+	// within it towards the mean time to resolve
+	const overlapping = "started_at < @to AND (resolved_at IS NULL OR resolved_at > @from)"
+	var incidents []struct {
+		EndpointID int
+		Count      int64
+		Downtime   float64
+		MTTR       float64
+	}
+	err = DB.Model(&Incident{}).
+		Select(`endpoint_id,
+			COUNT(*) FILTER (WHERE `+overlapping+`) AS count,
+			COALESCE(SUM(GREATEST(EXTRACT(EPOCH FROM (LEAST(COALESCE(resolved_at, @end), @end) - GREATEST(started_at, @from))), 0)) FILTER (WHERE `+overlapping+`), 0) AS downtime,
+			COALESCE(AVG(duration) FILTER (WHERE status = @resolved AND resolved_at >= @from AND resolved_at <= @to), 0) AS mttr`,
+			sql.Named("from", from), sql.Named("to", to), sql.Named("end", end), sql.Named("resolved", IncidentResolved)).
+		Where("endpoint_id IN ? AND started_at <= ? AND (resolved_at IS NULL OR resolved_at >= ?)", ids, to, from).
+		Group("endpoint_id").
+		Scan(&incidents).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range incidents {
+		stats := byID[row.EndpointID]
+		stats.Incidents = row.Count
+		stats.Downtime = int64(row.Downtime)
+		stats.MTTR = int64(row.MTTR)
+	}
+
+	for _, stats := range result {
+		if stats.TotalChecks > 0 {
+			stats.Uptime = float64(stats.TotalChecks-stats.FailedChecks) / float64(stats.TotalChecks) * 100
+		}
+		stats.SLABreached = stats.Uptime < stats.SLATarget
+	}
+	return result, nil
+}
+
+// DailyUptime counts the checks of an endpoint on a single UTC day
+type DailyUptime struct {
+	EndpointID   int    `json:"endpoint_id"`
+	Day          string `json:"day"` // YYYY-MM-DD
+	TotalChecks  int64  `json:"total_checks"`
+	FailedChecks int64  `json:"failed_checks"`
+}
+
+// GetDailyUptime counts the checks of the given endpoints per UTC day since from.
+// Days without checks are omitted.
+func GetDailyUptime(endpointIDs []int, from time.Time) ([]DailyUptime, error) {
+	var days []DailyUptime
+	if len(endpointIDs) == 0 {
+		return days, nil
+	}
+
+	err := DB.Model(&HealthCheck{}).
+		Select(`endpoint_id,
+			to_char(checked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day,
+			COUNT(*) AS total_checks,
+			COUNT(*) FILTER (WHERE result <> 'ok') AS failed_checks`).
+		Where("endpoint_id IN ? AND checked_at >= ? AND result NOT IN ('skipped', 'maintenance')", endpointIDs, from).
+		Group("endpoint_id, day").
+		Scan(&days).Error
+	if err != nil {
+		return nil, err
+	}
+	return days, nil
+}
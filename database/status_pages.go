@@ -0,0 +1,29 @@
+package database
+
+import "time"
+
+// GetStatusPageBySlug returns the status page with the given slug
+func GetStatusPageBySlug(slug string) (*StatusPage, error) {
+	var page StatusPage
+	if err := DB.Where("slug = ?", slug).First(&page).Error; err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetStatusPageIncidents returns the unresolved incidents of the given endpoints
+// and those that started since the given time, newest first
+func GetStatusPageIncidents(endpointIDs []int, since time.Time) ([]Incident, error) {
+	var incidents []Incident
+	if len(endpointIDs) == 0 {
+		return incidents, nil
+	}
+
+	err := DB.Where("endpoint_id IN ? AND (status <> ? OR started_at >= ?)", endpointIDs, IncidentResolved, since).
+		Order("started_at DESC").
+		Find(&incidents).Error
+	if err != nil {
+		return nil, err
+	}
+	return incidents, nil
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"time"
+)
+
+// apiTokenTouchInterval limits how often the last use of an API token is written
+const apiTokenTouchInterval = time.Minute
+
+// GetAPITokenByHash returns the unrevoked API token with the given hash
+func GetAPITokenByHash(hash string) (*APIToken, error) {
+	var token APIToken
+	if err := DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// TouchAPIToken records a use of an API token. Uses within a minute of the
+// recorded one are not written, so that busy tokens do not write on every request.
+func TouchAPIToken(token *APIToken, at time.Time, ip string) error {
+	if token.LastUsedAt != nil && at.Sub(*token.LastUsedAt) < apiTokenTouchInterval {
+		return nil
+	}
+	return DB.Model(token).Updates(map[string]interface{}{
+		"last_used_at": at,
+		"last_used_ip": ip,
+	}).Error
+}
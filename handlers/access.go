@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"api-monitor/database"
+
+	"github.com/labstack/echo/v4"
+)
+
+// currentOrg returns the organization the request acts on
+func currentOrg(c echo.Context) uint {
+	return c.Get("organization_id").(uint)
+}
+
+// currentRole returns the role of the current user in the organization the request acts on
+func currentRole(c echo.Context) string {
+	role, _ := c.Get("role").(string)
+	return role
+}
+
+// RequireRole returns middleware that rejects requests from members below the given role
+func RequireRole(min string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !database.RoleAtLeast(currentRole(c), min) {
+				return forbidden(c, min)
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireOperator returns middleware that rejects requests from users not
+// listed in auth.operators, for routes exposing information across organizations
+func RequireOperator() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var user database.User
+			if err := database.DB.Select("email").First(&user, c.Get("user_id").(uint)).Error; err != nil || !isOperator(user.Email) {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "This action requires an operator",
+				})
+			}
+			return next(c)
+		}
+	}
+}
+
+// isOperator reports whether email is listed in auth.operators
+func isOperator(email string) bool {
+	for _, operator := range appConfig.Auth.Operators {
+		if strings.EqualFold(strings.TrimSpace(operator), email) {
+			return true
+		}
+	}
+	return false
+}
+
+// forbidden writes the response for a member whose role is below min
+func forbidden(c echo.Context, min string) error {
+	return c.JSON(http.StatusForbidden, map[string]string{
+		"error": "This action requires the " + min + " role",
+	})
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"api-monitor/database"
 	"api-monitor/middleware"
@@ -24,34 +27,209 @@ func Login(c echo.Context) error {
 		})
 	}
 
+	// Back off and lock out by account and by address, whether or not the email exists
+	throttle := newLoginThrottle(req.Email, c.RealIP())
+	retryAt, err := throttle.count()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to check login attempts",
+		})
+	}
+	if wait := time.Until(retryAt); wait > 0 {
+		recordFailedLogin(c, req.Email, nil, database.LoginFailureThrottled)
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		return c.JSON(http.StatusTooManyRequests, map[string]string{
+			"error": "Too many failed login attempts, try again later",
+		})
+	}
+
 	var user database.User
 	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		// Take as long as a wrong password so that response times do not reveal the email is unknown
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(req.Password))
+		recordFailedLogin(c, req.Email, nil, database.LoginFailureUnknownEmail)
 		return c.JSON(http.StatusUnauthorized, map[string]string{
 			"error": "Invalid credentials",
 		})
 	}
 
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		recordFailedLogin(c, req.Email, &user.ID, database.LoginFailureWrongPassword)
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Invalid credentials",
+		})
+	}
+	throttle.succeeded()
+
+	// Only reveal that the account is inactive to someone who knows its password
 	if !user.IsActive {
+		recordFailedLogin(c, req.Email, &user.ID, database.LoginFailureInactive)
 		return c.JSON(http.StatusForbidden, map[string]string{
 			"error": "Account is inactive",
 		})
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	// Start a session with an access token and a refresh token
+	sessionID, err := middleware.NewSessionID()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to generate token",
+		})
+	}
+
+	tokens, refreshToken, err := issueTokens(user.ID, sessionID)
+	if err == nil {
+		err = database.CreateRefreshToken(refreshToken)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to generate token",
+		})
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// TokenResponse is a new access token and the refresh token to replace it with
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Seconds until the access token expires
+}
+
+// Refresh exchanges a refresh token for a new access token and refresh token.
+// Each refresh token can only be used once; presenting a used one ends its
+// session, since it means that the token leaked.
+func Refresh(c echo.Context) error {
+	type RefreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	req := new(RefreshRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	current, err := database.GetRefreshTokenByHash(middleware.HashToken(req.RefreshToken))
+	if err != nil {
 		return c.JSON(http.StatusUnauthorized, map[string]string{
-			"error": "Invalid credentials",
+			"error": "Invalid refresh token",
 		})
 	}
 
-	// Generate JWT token
-	token, err := middleware.GenerateToken(user.ID, []byte("your-secret-key")) // Replace with your secret key
+	now := time.Now()
+	if current.UsedAt != nil {
+		return refreshTokenReused(c, current, now)
+	}
+	if current.RevokedAt != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Session has ended",
+		})
+	}
+	if now.After(current.ExpiresAt) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Refresh token has expired",
+		})
+	}
+
+	var user database.User
+	if err := database.DB.First(&user, current.UserID).Error; err != nil || !user.IsActive {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "User account is inactive",
+		})
+	}
+
+	tokens, next, err := issueTokens(user.ID, current.FamilyID)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to generate token",
 		})
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{
-		"token": token,
+	if err := database.RotateRefreshToken(current, next, now); err != nil {
+		if err == database.ErrRefreshTokenReused {
+			return refreshTokenReused(c, current, now)
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to refresh token",
+		})
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// Logout ends the current session, revoking its access and refresh tokens
+func Logout(c echo.Context) error {
+	sessionID, ok := c.Get("session_id").(string)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Not signed in with a session",
+		})
+	}
+
+	if err := database.RevokeSession(sessionID, time.Now()); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to log out",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// LogoutAll ends every session of the current user, including the current one
+func LogoutAll(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	if err := database.RevokeUserSessions(userID, time.Now()); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to log out",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// issueTokens generates an access token and a refresh token for a session. The
+// refresh token is returned unsaved, along with the response holding both secrets.
+func issueTokens(userID uint, sessionID string) (*TokenResponse, *database.RefreshToken, error) {
+	now := time.Now()
+	accessExpiresAt := now.Add(appConfig.Auth.TokenTTL)
+	accessToken, accessTokenID, err := middleware.GenerateToken(userID, sessionID, []byte(appConfig.Auth.JWTSecret), accessExpiresAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secret, err := middleware.NewRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken := &database.RefreshToken{
+		UserID:          userID,
+		FamilyID:        sessionID,
+		TokenHash:       middleware.HashToken(secret),
+		AccessTokenID:   accessTokenID,
+		AccessExpiresAt: accessExpiresAt,
+		ExpiresAt:       now.Add(appConfig.Auth.RefreshTokenTTL),
+	}
+
+	tokens := &TokenResponse{
+		Token:        accessToken,
+		RefreshToken: secret,
+		ExpiresIn:    int(appConfig.Auth.TokenTTL.Seconds()),
+	}
+	return tokens, refreshToken, nil
+}
+
+// refreshTokenReused ends the session of a refresh token that was presented again
+func refreshTokenReused(c echo.Context, token *database.RefreshToken, at time.Time) error {
+	log.Printf("Refresh token reuse detected for user %d, revoking session %s", token.UserID, token.FamilyID)
+	if err := database.RevokeSession(token.FamilyID, at); err != nil {
+		log.Printf("Failed to revoke session %s: %v", token.FamilyID, err)
+	}
+	return c.JSON(http.StatusUnauthorized, map[string]string{
+		"error": "Refresh token was already used, the session has been ended",
 	})
 }
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"api-monitor/database"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetCertificates lists the TLS certificates of the current organization's endpoints, soonest expiry first
+func GetCertificates(c echo.Context) error {
+	orgID := currentOrg(c)
+
+	certificates, err := database.GetCertificates(orgID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch certificates",
+		})
+	}
+
+	return c.JSON(http.StatusOK, certificates)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/notifier"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ChannelRequest represents the request body for creating/updating a notification channel
+type ChannelRequest struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Target   string `json:"target"`
+	IsActive *bool  `json:"is_active"`
+}
+
+// CreateChannel handles the creation of a new notification channel
+func CreateChannel(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	orgID := currentOrg(c)
+
+	req := new(ChannelRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	channel := database.NotificationChannel{
+		OrganizationID: orgID,
+		UserID:         userID,
+		Name:           req.Name,
+		Type:           req.Type,
+		Target:         req.Target,
+		IsActive:       true,
+	}
+
+	if _, err := notifier.New(channel); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid channel: " + err.Error(),
+		})
+	}
+
+	if err := database.DB.Create(&channel).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create channel",
+		})
+	}
+
+	// gorm skips zero values on create, so a disabled channel needs a separate update
+	if req.IsActive != nil && !*req.IsActive {
+		if err := database.DB.Model(&channel).Update("is_active", false).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to create channel",
+			})
+		}
+	}
+
+	return c.JSON(http.StatusCreated, channel)
+}
+
+// GetChannels returns all notification channels for the current organization
+func GetChannels(c echo.Context) error {
+	orgID := currentOrg(c)
+
+	var channels []database.NotificationChannel
+	if err := database.DB.Where("organization_id = ?", orgID).Find(&channels).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch channels",
+		})
+	}
+
+	return c.JSON(http.StatusOK, channels)
+}
+
+// GetChannel returns a specific notification channel by ID
+func GetChannel(c echo.Context) error {
+	channel, err := findChannel(c)
+	if err != nil {
+		return lookupError(c, err, "Channel not found")
+	}
+
+	return c.JSON(http.StatusOK, channel)
+}
+
+// UpdateChannel updates an existing notification channel
+func UpdateChannel(c echo.Context) error {
+	channel, err := findChannel(c)
+	if err != nil {
+		return lookupError(c, err, "Channel not found")
+	}
+
+	req := new(ChannelRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	channel.Name = req.Name
+	channel.Type = req.Type
+	channel.Target = req.Target
+	if req.IsActive != nil {
+		channel.IsActive = *req.IsActive
+	}
+
+	if _, err := notifier.New(*channel); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid channel: " + err.Error(),
+		})
+	}
+
+	updates := map[string]interface{}{
+		"name":      channel.Name,
+		"type":      channel.Type,
+		"target":    channel.Target,
+		"is_active": channel.IsActive,
+	}
+
+	if err := database.DB.Model(channel).Updates(updates).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update channel",
+		})
+	}
+
+	return c.JSON(http.StatusOK, channel)
+}
+
+// DeleteChannel removes a notification channel
+func DeleteChannel(c echo.Context) error {
+	channel, err := findChannel(c)
+	if err != nil {
+		return lookupError(c, err, "Channel not found")
+	}
+
+	if err := database.DB.Delete(channel).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete channel",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// TestChannel sends a test notification through a channel
+func TestChannel(c echo.Context) error {
+	channel, err := findChannel(c)
+	if err != nil {
+		return lookupError(c, err, "Channel not found")
+	}
+
+	event := notifier.Event{
+		Type: notifier.EventTest,
+		URL:  "https://example.com/health",
+		Time: time.Now(),
+	}
+
+	if err := notifier.Send(*channel, event); err != nil {
+		return c.JSON(http.StatusBadGateway, map[string]string{
+			"error": "Failed to send test notification: " + err.Error(),
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// findChannel loads the channel identified by the id path parameter for the current organization
+func findChannel(c echo.Context) (*database.NotificationChannel, error) {
+	orgID := currentOrg(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var channel database.NotificationChannel
+	if err := database.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&channel).Error; err != nil {
+		return nil, err
+	}
+
+	return &channel, nil
+}
@@ -1,48 +1,30 @@
 package handlers
 
 import (
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
 	"api-monitor/database"
 	"api-monitor/models"
+	"api-monitor/monitor"
 
 	"fmt"
 
-	"log"
-
 	"github.com/labstack/echo/v4"
-	"github.com/lib/pq"
-)
-
-var (
-	endpoints []models.Endpoint
-	mu        sync.RWMutex
 )
 
-// LoadEndpoints loads endpoints from the database
-func LoadEndpoints() error {
-	dbEndpoints, err := database.LoadEndpoints()
-	if err != nil {
-		return err
-	}
-
-	mu.Lock()
-	endpoints = dbEndpoints
-	mu.Unlock()
-
-	return nil
-}
-
 // CreateEndpoint handles the creation of a new endpoint
 func CreateEndpoint(c echo.Context) error {
 	userID := c.Get("user_id").(uint)
+	orgID := currentOrg(c)
 
-	// Check user's subscription
+	// Check the organization's subscription
 	var subscription database.Subscription
-	if err := database.DB.Where("user_id = ?", userID).First(&subscription).Error; err != nil {
+	if err := database.DB.Where("organization_id = ?", orgID).First(&subscription).Error; err != nil {
 		return c.JSON(http.StatusForbidden, map[string]string{
 			"error": "No active subscription found",
 		})
@@ -55,9 +37,9 @@ func CreateEndpoint(c echo.Context) error {
 		})
 	}
 
-	// Check if user has reached endpoint limit
+	// Check if the organization has reached its endpoint limit
 	var endpointCount int64
-	if err := database.DB.Model(&database.Endpoint{}).Where("user_id = ?", userID).Count(&endpointCount).Error; err != nil {
+	if err := database.DB.Model(&database.Endpoint{}).Where("organization_id = ?", orgID).Count(&endpointCount).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to check endpoint count",
 		})
@@ -92,6 +74,18 @@ func CreateEndpoint(c echo.Context) error {
 		})
 	}
 
+	if err := validateEndpoint(endpoint); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	if err := validateEscalationPolicyID(endpoint.EscalationPolicyID, orgID); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
 	// Set default expiry date if not provided (30 days from now)
 	if endpoint.ExpiresAt.IsZero() {
 		endpoint.ExpiresAt = time.Now().AddDate(0, 0, 30)
@@ -99,8 +93,14 @@ func CreateEndpoint(c echo.Context) error {
 
 	// Create endpoint in database
 	dbEndpoint := database.FromModel(*endpoint)
+	dbEndpoint.OrganizationID = orgID
 	dbEndpoint.UserID = userID
 	dbEndpoint.LastChecked = time.Now()
+	if err := prepareHeartbeat(&dbEndpoint); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to generate heartbeat token",
+		})
+	}
 
 	if err := database.DB.Create(&dbEndpoint).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
@@ -108,52 +108,26 @@ func CreateEndpoint(c echo.Context) error {
 		})
 	}
 
-	// Set the endpoint ID from the database
+	// Set the endpoint ID, owner and heartbeat settings from the database
 	endpoint.ID = int(dbEndpoint.ID)
+	endpoint.OrganizationID = orgID
+	endpoint.UserID = userID
+	endpoint.HeartbeatToken = dbEndpoint.HeartbeatToken
+	endpoint.LastPingAt = dbEndpoint.LastPingAt
 
-	// Create new schedule
-	schedule := &database.Schedule{
-		Name:      fmt.Sprintf("Schedule for %d second interval", endpoint.Interval),
-		Interval:  endpoint.Interval,
-		CreatedAt: time.Now(),
-		Endpoints: pq.Int64Array{int64(dbEndpoint.ID)},
-	}
-
-	// Try to find existing schedule for this interval
-	var existingSchedule database.Schedule
-	if err := database.DB.Unscoped().Where("interval = ?", endpoint.Interval).First(&existingSchedule).Error; err == nil {
-		// Schedule exists, append the new endpoint ID
-		existingSchedule.Endpoints = append(existingSchedule.Endpoints, int64(dbEndpoint.ID))
-		if err := database.DB.Unscoped().Save(&existingSchedule).Error; err != nil {
-			// If schedule update fails, rollback endpoint creation
-			database.DB.Delete(&dbEndpoint)
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "Failed to update schedule",
-			})
-		}
-	} else {
-		// Create new schedule
-		if err := database.DB.Create(schedule).Error; err != nil {
-			// If schedule creation fails, rollback endpoint creation
-			database.DB.Delete(&dbEndpoint)
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "Failed to create schedule",
-			})
-		}
-	}
-
-	// Perform initial health check
-	go checkEndpoint(endpoint)
+	// Start monitoring, with an initial health check right away
+	checkScheduler.Add(*endpoint)
+	checkScheduler.Trigger(endpoint.ID)
 
 	return c.JSON(http.StatusCreated, endpoint)
 }
 
-// GetEndpoints returns all endpoints for the current user
+// GetEndpoints returns all endpoints for the current organization
 func GetEndpoints(c echo.Context) error {
-	userID := c.Get("user_id").(uint)
+	orgID := currentOrg(c)
 
 	var dbEndpoints []database.Endpoint
-	if err := database.DB.Where("user_id = ?", userID).Find(&dbEndpoints).Error; err != nil {
+	if err := database.DB.Where("organization_id = ?", orgID).Find(&dbEndpoints).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to fetch endpoints",
 		})
@@ -170,7 +144,7 @@ func GetEndpoints(c echo.Context) error {
 
 // GetEndpoint returns a specific endpoint by ID
 func GetEndpoint(c echo.Context) error {
-	userID := c.Get("user_id").(uint)
+	orgID := currentOrg(c)
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
@@ -179,7 +153,7 @@ func GetEndpoint(c echo.Context) error {
 	}
 
 	var dbEndpoint database.Endpoint
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&dbEndpoint).Error; err != nil {
+	if err := database.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&dbEndpoint).Error; err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "Endpoint not found",
 		})
@@ -190,7 +164,7 @@ func GetEndpoint(c echo.Context) error {
 
 // UpdateEndpoint updates an existing endpoint
 func UpdateEndpoint(c echo.Context) error {
-	userID := c.Get("user_id").(uint)
+	orgID := currentOrg(c)
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
@@ -198,9 +172,9 @@ func UpdateEndpoint(c echo.Context) error {
 		})
 	}
 
-	// Check if endpoint exists and belongs to user
+	// Check if endpoint exists and belongs to the organization
 	var existingEndpoint database.Endpoint
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&existingEndpoint).Error; err != nil {
+	if err := database.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&existingEndpoint).Error; err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "Endpoint not found",
 		})
@@ -208,7 +182,7 @@ func UpdateEndpoint(c echo.Context) error {
 
 	// Check subscription for interval validation
 	var subscription database.Subscription
-	if err := database.DB.Where("user_id = ?", userID).First(&subscription).Error; err != nil {
+	if err := database.DB.Where("organization_id = ?", orgID).First(&subscription).Error; err != nil {
 		return c.JSON(http.StatusForbidden, map[string]string{
 			"error": "No active subscription found",
 		})
@@ -236,25 +210,45 @@ func UpdateEndpoint(c echo.Context) error {
 		})
 	}
 
-	// Update endpoint
-	updates := map[string]interface{}{
-		"url":        endpoint.URL,
-		"interval":   endpoint.Interval,
-		"expires_at": endpoint.ExpiresAt,
+	if err := validateEndpoint(endpoint); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	if err := validateEscalationPolicyID(endpoint.EscalationPolicyID, orgID); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	// Update endpoint, selecting the columns so that cleared values are written too
+	updates := database.FromModel(*endpoint)
+	updates.HeartbeatToken = existingEndpoint.HeartbeatToken
+	updates.LastPingAt = existingEndpoint.LastPingAt
+	if err := prepareHeartbeat(&updates); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to generate heartbeat token",
+		})
 	}
+	columns := []string{"type", "url", "interval", "expires_at", "method", "headers", "body", "expected_status", "assertions", "send", "expect", "record_type", "resolver", "expected_values", "grpc_service", "grpc_tls", "steps", "heartbeat_token", "grace_period", "last_ping_at", "cert_expiry_days", "sla_target", "tags", "escalation_policy_id"}
 
-	if err := database.DB.Model(&existingEndpoint).Updates(updates).Error; err != nil {
+	if err := database.DB.Model(&existingEndpoint).Select(columns).Updates(&updates).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to update endpoint",
 		})
 	}
 
-	return c.JSON(http.StatusOK, existingEndpoint.ToModel())
+	// Apply the new settings to the running schedule
+	updated := existingEndpoint.ToModel()
+	checkScheduler.Update(updated)
+
+	return c.JSON(http.StatusOK, updated)
 }
 
 // DeleteEndpoint removes an endpoint from monitoring
 func DeleteEndpoint(c echo.Context) error {
-	userID := c.Get("user_id").(uint)
+	orgID := currentOrg(c)
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
@@ -262,45 +256,300 @@ func DeleteEndpoint(c echo.Context) error {
 		})
 	}
 
-	// Get the endpoint to find its interval
+	// Check if endpoint exists and belongs to the organization
 	var endpoint database.Endpoint
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&endpoint).Error; err != nil {
+	if err := database.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&endpoint).Error; err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "Endpoint not found",
 		})
 	}
 
-	// Find the schedule for this interval
-	var schedule database.Schedule
-	if err := database.DB.Unscoped().Where("interval = ?", endpoint.Interval).First(&schedule).Error; err == nil {
-		// Remove the endpoint ID from the schedule's endpoints array
-		newEndpoints := make(pq.Int64Array, 0)
-		for _, endpointID := range schedule.Endpoints {
-			if endpointID != int64(id) {
-				newEndpoints = append(newEndpoints, endpointID)
-			}
-		}
-		schedule.Endpoints = newEndpoints
-
-		// If no endpoints left, delete the schedule
-		if len(schedule.Endpoints) == 0 {
-			if err := database.DB.Unscoped().Delete(&schedule).Error; err != nil {
-				log.Printf("Failed to delete empty schedule: %v", err)
-			}
-		} else {
-			// Update the schedule with the new endpoints list
-			if err := database.DB.Unscoped().Save(&schedule).Error; err != nil {
-				log.Printf("Failed to update schedule: %v", err)
-			}
-		}
-	}
-
 	// Delete the endpoint
 	if err := database.DB.Delete(&endpoint).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to delete endpoint",
 		})
 	}
+	checkScheduler.Remove(id)
+	monitor.ForgetEndpoint(id)
 
 	return c.NoContent(http.StatusNoContent)
 }
+
+// prepareHeartbeat issues the ping token of a heartbeat endpoint that has none
+// yet and starts its first interval, so a new heartbeat is not overdue right away
+func prepareHeartbeat(endpoint *database.Endpoint) error {
+	if endpoint.Type != models.TypeHeartbeat || endpoint.HeartbeatToken != "" {
+		return nil
+	}
+
+	token, err := newHeartbeatToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	endpoint.HeartbeatToken = token
+	endpoint.LastPingAt = &now
+	return nil
+}
+
+// validHTTPMethods lists the request methods an HTTP probe may use
+var validHTTPMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// validateEndpoint normalizes and validates the probe and reporting settings of an endpoint
+func validateEndpoint(endpoint *models.Endpoint) error {
+	endpoint.Type = strings.ToLower(strings.TrimSpace(endpoint.Type))
+	if endpoint.Type == "" {
+		endpoint.Type = models.TypeHTTP
+	}
+
+	if err := validateSettingTypes(endpoint); err != nil {
+		return err
+	}
+
+	var err error
+	switch endpoint.Type {
+	case models.TypeHTTP:
+		err = validateHTTPEndpoint(endpoint)
+	case models.TypeTCP, models.TypeGRPC:
+		err = validateAddress(endpoint)
+	case models.TypeDNS:
+		err = validateDNSEndpoint(endpoint)
+	case models.TypeHeartbeat:
+		err = validateHeartbeatEndpoint(endpoint)
+	case models.TypeTransaction:
+		err = validateTransactionEndpoint(endpoint)
+	default:
+		err = fmt.Errorf("Invalid monitor type %q", endpoint.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	if endpoint.CertExpiryDays < 0 {
+		return fmt.Errorf("Invalid cert_expiry_days, must not be negative")
+	}
+
+	if endpoint.SLATarget < 0 || endpoint.SLATarget > 100 {
+		return fmt.Errorf("Invalid SLA target, must be a percentage")
+	}
+
+	endpoint.Tags = normalizeTags(endpoint.Tags)
+
+	return nil
+}
+
+// validateHTTPEndpoint validates the settings of an http endpoint
+func validateHTTPEndpoint(endpoint *models.Endpoint) error {
+	u, err := url.Parse(endpoint.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid URL %q, must be an http or https URL", endpoint.URL)
+	}
+	endpoint.Method = strings.ToUpper(strings.TrimSpace(endpoint.Method))
+	if endpoint.Method == "" {
+		endpoint.Method = http.MethodGet
+	}
+	if !validHTTPMethods[endpoint.Method] {
+		return fmt.Errorf("Invalid HTTP method %q", endpoint.Method)
+	}
+
+	for name := range endpoint.Headers {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " :\r\n") {
+			return fmt.Errorf("Invalid header name %q", name)
+		}
+	}
+
+	for _, code := range endpoint.ExpectedStatus {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("Invalid expected status code %d", code)
+		}
+	}
+
+	for _, assertion := range endpoint.Assertions {
+		if err := monitor.ValidateAssertion(assertion); err != nil {
+			return fmt.Errorf("Invalid assertion: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// validateAddress validates the host:port url of a tcp or grpc endpoint
+func validateAddress(endpoint *models.Endpoint) error {
+	host, port, err := net.SplitHostPort(endpoint.URL)
+	if err != nil || host == "" {
+		return fmt.Errorf("Invalid address %q, must be host:port", endpoint.URL)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("Invalid port %q", port)
+	}
+	return nil
+}
+
+// validDNSRecordTypes lists the record types a dns endpoint may resolve
+var validDNSRecordTypes = map[string]bool{
+	models.RecordA:     true,
+	models.RecordAAAA:  true,
+	models.RecordCNAME: true,
+	models.RecordMX:    true,
+	models.RecordTXT:   true,
+}
+
+// validateDNSEndpoint validates the settings of a dns endpoint
+func validateDNSEndpoint(endpoint *models.Endpoint) error {
+	endpoint.URL = strings.TrimSuffix(strings.TrimSpace(endpoint.URL), ".")
+	if !isDomainName(endpoint.URL) {
+		return fmt.Errorf("Invalid domain name %q", endpoint.URL)
+	}
+
+	endpoint.RecordType = strings.ToUpper(strings.TrimSpace(endpoint.RecordType))
+	if endpoint.RecordType == "" {
+		endpoint.RecordType = models.RecordA
+	}
+	if !validDNSRecordTypes[endpoint.RecordType] {
+		return fmt.Errorf("Invalid record type %q", endpoint.RecordType)
+	}
+
+	if endpoint.Resolver != "" {
+		host, port, err := net.SplitHostPort(endpoint.Resolver)
+		if err != nil {
+			host, port = endpoint.Resolver, "53"
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 || host == "" {
+			return fmt.Errorf("Invalid resolver %q, must be host or host:port", endpoint.Resolver)
+		}
+		endpoint.Resolver = net.JoinHostPort(host, port)
+	}
+
+	for _, value := range endpoint.ExpectedValues {
+		var ok bool
+		switch endpoint.RecordType {
+		case models.RecordA:
+			ip := net.ParseIP(value)
+			ok = ip != nil && ip.To4() != nil
+		case models.RecordAAAA:
+			ip := net.ParseIP(value)
+			ok = ip != nil && ip.To4() == nil
+		case models.RecordCNAME, models.RecordMX:
+			ok = isDomainName(strings.TrimSuffix(value, "."))
+		default:
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("Invalid expected %s value %q", endpoint.RecordType, value)
+		}
+	}
+	return nil
+}
+
+// validateHeartbeatEndpoint validates the settings of a heartbeat endpoint
+func validateHeartbeatEndpoint(endpoint *models.Endpoint) error {
+	endpoint.URL = strings.TrimSpace(endpoint.URL)
+	if endpoint.URL == "" {
+		return fmt.Errorf("A heartbeat endpoint needs a name as url")
+	}
+	if endpoint.GracePeriod < 0 {
+		return fmt.Errorf("Invalid grace_period, must not be negative")
+	}
+	return nil
+}
+
+// maxTransactionSteps is the number of steps a transaction may have
+const maxTransactionSteps = 20
+
+// validateTransactionEndpoint validates the steps of a transaction endpoint
+func validateTransactionEndpoint(endpoint *models.Endpoint) error {
+	endpoint.URL = strings.TrimSpace(endpoint.URL)
+	if endpoint.URL == "" {
+		return fmt.Errorf("A transaction endpoint needs a name as url")
+	}
+	if len(endpoint.Steps) == 0 || len(endpoint.Steps) > maxTransactionSteps {
+		return fmt.Errorf("A transaction needs between 1 and %d steps", maxTransactionSteps)
+	}
+
+	for i := range endpoint.Steps {
+		step := &endpoint.Steps[i]
+
+		// Validate the request like an http endpoint, with variable references filled in
+		request := models.Endpoint{
+			URL:            monitor.StripVariables(step.URL),
+			Method:         step.Method,
+			Headers:        step.Headers,
+			ExpectedStatus: step.ExpectedStatus,
+			Assertions:     step.Assertions,
+		}
+		if err := validateHTTPEndpoint(&request); err != nil {
+			return fmt.Errorf("Step %d: %v", i+1, err)
+		}
+		step.Method = request.Method
+	}
+
+	if err := monitor.ValidateTransaction(endpoint.Steps); err != nil {
+		return fmt.Errorf("Invalid transaction: %v", err)
+	}
+	return nil
+}
+
+// isDomainName reports whether name is a syntactically valid domain name
+func isDomainName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// normalizeTags lowercases and trims tags, dropping empty and duplicate ones
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// validateSettingTypes rejects settings that do not apply to the endpoint's monitor type
+func validateSettingTypes(endpoint *models.Endpoint) error {
+	if endpoint.Type != models.TypeHTTP && (endpoint.Method != "" || len(endpoint.Headers) > 0 || endpoint.Body != "" ||
+		len(endpoint.ExpectedStatus) > 0 || len(endpoint.Assertions) > 0) {
+		return fmt.Errorf("method, headers, body, expected_status and assertions only apply to http endpoints")
+	}
+	if endpoint.Type != models.TypeTCP && (endpoint.Send != "" || endpoint.Expect != "") {
+		return fmt.Errorf("send and expect only apply to tcp endpoints")
+	}
+	if endpoint.Type != models.TypeGRPC && (endpoint.GRPCService != "" || endpoint.GRPCTLS) {
+		return fmt.Errorf("grpc_service and grpc_tls only apply to grpc endpoints")
+	}
+	if endpoint.Type != models.TypeTransaction && len(endpoint.Steps) > 0 {
+		return fmt.Errorf("steps only apply to transaction endpoints")
+	}
+	if endpoint.Type != models.TypeHeartbeat && endpoint.GracePeriod != 0 {
+		return fmt.Errorf("grace_period only applies to heartbeat endpoints")
+	}
+	if endpoint.Type != models.TypeDNS && (endpoint.RecordType != "" || endpoint.Resolver != "" || len(endpoint.ExpectedValues) > 0) {
+		return fmt.Errorf("record_type, resolver and expected_values only apply to dns endpoints")
+	}
+	return nil
+}
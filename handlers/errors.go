@@ -1,8 +1,24 @@
 package handlers
 
-import "errors"
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
 
 var (
-	ErrEndpointNotFound = errors.New("endpoint not found")
-	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidID = errors.New("invalid ID format")
 )
+
+// lookupError writes the response for a resource that could not be loaded by ID
+func lookupError(c echo.Context, err error, notFoundMessage string) error {
+	if err == ErrInvalidID {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid ID format",
+		})
+	}
+	return c.JSON(http.StatusNotFound, map[string]string{
+		"error": notFoundMessage,
+	})
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api-monitor/database"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxEscalationLevels limits the number of levels of an escalation policy
+const maxEscalationLevels = 5
+
+// EscalationPolicyRequest represents the request body for creating/updating an escalation policy
+type EscalationPolicyRequest struct {
+	Name   string                     `json:"name"`
+	Levels []database.EscalationLevel `json:"levels"`
+}
+
+// CreateEscalationPolicy handles the creation of a new escalation policy
+func CreateEscalationPolicy(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	orgID := currentOrg(c)
+
+	req := new(EscalationPolicyRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	policy := database.EscalationPolicy{
+		OrganizationID: orgID,
+		UserID:         userID,
+		Name:           strings.TrimSpace(req.Name),
+		Levels:         req.Levels,
+	}
+
+	if err := validateEscalationPolicy(policy); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid escalation policy: " + err.Error(),
+		})
+	}
+
+	if err := database.DB.Create(&policy).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create escalation policy",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, policy)
+}
+
+// GetEscalationPolicies returns all escalation policies for the current organization
+func GetEscalationPolicies(c echo.Context) error {
+	orgID := currentOrg(c)
+
+	var policies []database.EscalationPolicy
+	if err := database.DB.Where("organization_id = ?", orgID).Order("id").Find(&policies).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch escalation policies",
+		})
+	}
+
+	return c.JSON(http.StatusOK, policies)
+}
+
+// GetEscalationPolicy returns a specific escalation policy by ID
+func GetEscalationPolicy(c echo.Context) error {
+	policy, err := findEscalationPolicy(c)
+	if err != nil {
+		return lookupError(c, err, "Escalation policy not found")
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+// UpdateEscalationPolicy updates an existing escalation policy. Open incidents
+// continue with the new levels from the level they have reached.
+func UpdateEscalationPolicy(c echo.Context) error {
+	policy, err := findEscalationPolicy(c)
+	if err != nil {
+		return lookupError(c, err, "Escalation policy not found")
+	}
+
+	req := new(EscalationPolicyRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	policy.Name = strings.TrimSpace(req.Name)
+	policy.Levels = req.Levels
+
+	if err := validateEscalationPolicy(*policy); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid escalation policy: " + err.Error(),
+		})
+	}
+
+	// Map updates skip the json serializer, so update from the struct
+	if err := database.DB.Model(policy).Select("name", "levels").Updates(policy).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update escalation policy",
+		})
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+// DeleteEscalationPolicy removes an escalation policy that is no longer assigned to any
+// endpoint nor used by an unresolved incident
+func DeleteEscalationPolicy(c echo.Context) error {
+	policy, err := findEscalationPolicy(c)
+	if err != nil {
+		return lookupError(c, err, "Escalation policy not found")
+	}
+
+	var assigned int64
+	if err := database.DB.Model(&database.Endpoint{}).Where("escalation_policy_id = ?", policy.ID).Count(&assigned).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete escalation policy",
+		})
+	}
+	if assigned > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": fmt.Sprintf("Escalation policy is assigned to %d endpoints", assigned),
+		})
+	}
+
+	// Unresolved incidents still escalate and send their recovery alerts through the policy
+	var unresolved int64
+	if err := database.DB.Model(&database.Incident{}).Where("escalation_policy_id = ? AND status <> ?", policy.ID, database.IncidentResolved).Count(&unresolved).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete escalation policy",
+		})
+	}
+	if unresolved > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": fmt.Sprintf("Escalation policy is used by %d unresolved incidents", unresolved),
+		})
+	}
+
+	if err := database.DB.Delete(policy).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete escalation policy",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// validateEscalationPolicy checks the levels of a policy and that their channels belong to its organization
+func validateEscalationPolicy(policy database.EscalationPolicy) error {
+	if policy.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(policy.Levels) == 0 || len(policy.Levels) > maxEscalationLevels {
+		return fmt.Errorf("a policy needs between 1 and %d levels", maxEscalationLevels)
+	}
+
+	channelIDs := make(map[uint]bool)
+	for i, level := range policy.Levels {
+		switch {
+		case i == 0 && level.Delay != 0:
+			return errors.New("level 1 is notified immediately, its delay must be 0")
+		case i > 0 && level.Delay <= policy.Levels[i-1].Delay:
+			return fmt.Errorf("level %d must have a longer delay than level %d", i+1, i)
+		case level.RepeatInterval < 0:
+			return fmt.Errorf("level %d: repeat_interval must not be negative", i+1)
+		case len(level.ChannelIDs) == 0:
+			return fmt.Errorf("level %d needs at least one channel", i+1)
+		}
+		for _, id := range level.ChannelIDs {
+			channelIDs[id] = true
+		}
+	}
+
+	ids := make([]uint, 0, len(channelIDs))
+	for id := range channelIDs {
+		ids = append(ids, id)
+	}
+	var count int64
+	if err := database.DB.Model(&database.NotificationChannel{}).
+		Where("id IN ? AND organization_id = ?", ids, policy.OrganizationID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count != int64(len(ids)) {
+		return errors.New("channel_ids refers to unknown channels")
+	}
+	return nil
+}
+
+// validateEscalationPolicyID checks that an endpoint's escalation policy, if set, belongs to the organization
+func validateEscalationPolicyID(id *uint, orgID uint) error {
+	if id == nil {
+		return nil
+	}
+
+	var count int64
+	if err := database.DB.Model(&database.EscalationPolicy{}).Where("id = ? AND organization_id = ?", *id, orgID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("Unknown escalation policy %d", *id)
+	}
+	return nil
+}
+
+// findEscalationPolicy loads the escalation policy identified by the id path parameter for the current organization
+func findEscalationPolicy(c echo.Context) (*database.EscalationPolicy, error) {
+	orgID := currentOrg(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var policy database.EscalationPolicy
+	if err := database.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&policy).Error; err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
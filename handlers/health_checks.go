@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-monitor/database"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultChecksPerPage = 50
+	maxChecksPerPage     = 500
+)
+
+// GetEndpointChecks returns the paginated health check history of an endpoint.
+// Supports the optional query parameters from and to (RFC 3339), page and per_page.
+func GetEndpointChecks(c echo.Context) error {
+	orgID := currentOrg(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid ID format",
+		})
+	}
+
+	var endpoint database.Endpoint
+	if err := database.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&endpoint).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Endpoint not found",
+		})
+	}
+
+	var from, to time.Time
+	if v := c.QueryParam("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid from timestamp, expected RFC 3339",
+			})
+		}
+	}
+	if v := c.QueryParam("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid to timestamp, expected RFC 3339",
+			})
+		}
+	}
+
+	page := 1
+	if v := c.QueryParam("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid page",
+			})
+		}
+	}
+
+	perPage := defaultChecksPerPage
+	if v := c.QueryParam("per_page"); v != "" {
+		if perPage, err = strconv.Atoi(v); err != nil || perPage < 1 || perPage > maxChecksPerPage {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid per_page, must be between 1 and " + strconv.Itoa(maxChecksPerPage),
+			})
+		}
+	}
+
+	checks, total, err := database.GetHealthChecks(id, from, to, perPage, (page-1)*perPage)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch health checks",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"checks":   checks,
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+	})
+}
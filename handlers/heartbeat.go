@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"api-monitor/database"
+	"api-monitor/monitor"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxPingBodySize is the number of request body bytes read from a heartbeat ping
+const maxPingBodySize = 4096
+
+// Heartbeat records a ping of a heartbeat endpoint. The token in the path is the
+// credential, so the route is public.
+func Heartbeat(c echo.Context) error {
+	dbEndpoint, err := database.GetHeartbeatEndpoint(c.Param("token"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Heartbeat not found",
+		})
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(c.Request().Body, maxPingBodySize))
+
+	endpoint := dbEndpoint.ToModel()
+	monitor.RecordHeartbeat(&endpoint, body)
+
+	return c.JSON(http.StatusOK, map[string]string{
+		"status": "ok",
+	})
+}
+
+// newHeartbeatToken generates the secret ping token of a heartbeat endpoint
+func newHeartbeatToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"api-monitor/broker"
+	"api-monitor/database"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetIncidents returns the incidents of the current organization's endpoints, newest first.
+// Supports the optional query parameters status and endpoint_id.
+func GetIncidents(c echo.Context) error {
+	orgID := currentOrg(c)
+
+	query := database.DB.Where("organization_id = ?", orgID)
+	if status := c.QueryParam("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if v := c.QueryParam("endpoint_id"); v != "" {
+		endpointID, err := strconv.Atoi(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Invalid endpoint_id",
+			})
+		}
+		query = query.Where("endpoint_id = ?", endpointID)
+	}
+
+	var incidents []database.Incident
+	if err := query.Order("started_at DESC").Find(&incidents).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch incidents",
+		})
+	}
+
+	return c.JSON(http.StatusOK, incidents)
+}
+
+// GetIncident returns an incident with its timeline and the checks attached to it
+func GetIncident(c echo.Context) error {
+	incident, err := findIncident(c)
+	if err != nil {
+		return lookupError(c, err, "Incident not found")
+	}
+
+	if err := database.DB.Model(incident).Order("created_at").Association("Events").Find(&incident.Events); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch incident timeline",
+		})
+	}
+	if err := database.DB.Model(incident).Order("checked_at").Association("Checks").Find(&incident.Checks); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch incident checks",
+		})
+	}
+
+	return c.JSON(http.StatusOK, incident)
+}
+
+// AcknowledgeIncident marks an open incident as being handled by the current user
+func AcknowledgeIncident(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	incident, err := findIncident(c)
+	if err != nil {
+		return lookupError(c, err, "Incident not found")
+	}
+
+	type AckRequest struct {
+		Note string `json:"note"`
+	}
+
+	req := new(AckRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	if err := database.AcknowledgeIncident(incident, userID, req.Note); err != nil {
+		if err == database.ErrIncidentNotOpen {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Incident is not open",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to acknowledge incident",
+		})
+	}
+
+	message := "Incident acknowledged"
+	if req.Note != "" {
+		message += ": " + req.Note
+	}
+	broker.Publish(broker.Event{
+		Type:           broker.EventIncident,
+		OrganizationID: incident.OrganizationID,
+		EndpointID:     incident.EndpointID,
+		Data: broker.IncidentChange{
+			IncidentID: incident.ID,
+			Status:     database.IncidentAcknowledged,
+			Message:    message,
+		},
+	})
+
+	return c.JSON(http.StatusOK, incident)
+}
+
+// findIncident loads the incident identified by the id path parameter for the current organization
+func findIncident(c echo.Context) (*database.Incident, error) {
+	orgID := currentOrg(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var incident database.Incident
+	if err := database.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&incident).Error; err != nil {
+		return nil, err
+	}
+
+	return &incident, nil
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"api-monitor/database"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// loginThrottle identifies the account and address of a login attempt for
+// counting failures against both
+type loginThrottle struct {
+	accountKey string
+	ipKey      string
+}
+
+// newLoginThrottle returns the failure counter keys of a login attempt
+func newLoginThrottle(email, ip string) loginThrottle {
+	return loginThrottle{
+		accountKey: "account:" + strings.ToLower(strings.TrimSpace(email)),
+		ipKey:      "ip:" + ip,
+	}
+}
+
+// newRegistrationThrottle returns the counter keys of a registration attempt.
+// They are kept apart from the login counters, so that registering someone
+// else's email cannot lock them out of their account.
+func newRegistrationThrottle(email, ip string) loginThrottle {
+	t := newLoginThrottle(email, ip)
+	t.accountKey = "register:" + t.accountKey
+	t.ipKey = "register:" + t.ipKey
+	return t
+}
+
+// keys returns the counter keys of the attempt
+func (t loginThrottle) keys() []string {
+	return []string{t.accountKey, t.ipKey}
+}
+
+// count counts the attempt against its account and address before the
+// password is checked, and returns when it may be made, the zero time if now.
+// Attempts made before that time are counted too.
+func (t loginThrottle) count() (time.Time, error) {
+	throttles, err := database.CountAttempt(t.keys(), time.Now(), appConfig.Auth.LoginLockout)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	auth := appConfig.Auth
+	var until time.Time
+	for _, throttle := range throttles {
+		threshold := auth.LoginMaxFailures
+		if throttle.Key == t.ipKey {
+			threshold = auth.LoginMaxIPFailures
+		}
+		if u := loginRetryAt(throttle, threshold, auth.LoginLockout); u.After(until) {
+			until = u
+		}
+	}
+	return until, nil
+}
+
+// succeeded takes back the count of an attempt with the right password. The
+// account starts over, while the address keeps its earlier failures so that
+// signing in to one account does not clear failures against others.
+func (t loginThrottle) succeeded() {
+	if err := database.ResetLoginFailures(t.accountKey); err != nil {
+		log.Printf("Failed to reset login failures of %s: %v", t.accountKey, err)
+	}
+	if err := database.UncountAttempt(t.ipKey); err != nil {
+		log.Printf("Failed to uncount login attempt of %s: %v", t.ipKey, err)
+	}
+}
+
+// loginRetryAt returns when a key may try to sign in again after its recent
+// failures. The first half of the allowed failures are free, each later one
+// doubles the wait from one second, and reaching threshold locks the key until
+// lockout has passed since its last failure.
+func loginRetryAt(throttle database.LoginThrottle, threshold int, lockout time.Duration) time.Time {
+	if throttle.Failures >= threshold {
+		return throttle.LastFailureAt.Add(lockout)
+	}
+
+	free := threshold / 2
+	if throttle.Failures <= free {
+		return time.Time{}
+	}
+
+	delay := lockout
+	if shift := throttle.Failures - free - 1; shift < 30 && time.Second<<shift < lockout {
+		delay = time.Second << shift
+	}
+	return throttle.LastFailureAt.Add(delay)
+}
+
+// recordFailedLogin adds a failed login to the audit trail
+func recordFailedLogin(c echo.Context, email string, userID *uint, reason string) {
+	failure := &database.FailedLogin{
+		CreatedAt: time.Now(),
+		Email:     email,
+		UserID:    userID,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Reason:    reason,
+	}
+	if err := database.RecordFailedLogin(failure); err != nil {
+		log.Printf("Failed to record failed login for %s: %v", email, err)
+	}
+}
+
+// dummyPasswordHash is compared against when the email is unknown, so that
+// responses take as long as for a wrong password
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("Failed to generate dummy password hash: %v", err)
+	}
+	return hash
+})
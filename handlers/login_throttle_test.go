@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"api-monitor/database"
+)
+
+func TestLoginRetryAt(t *testing.T) {
+	last := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		failures  int
+		threshold int
+		lockout   time.Duration
+		want      time.Time
+	}{
+		{"no failures", 0, 5, 15 * time.Minute, time.Time{}},
+		{"free failures", 2, 5, 15 * time.Minute, time.Time{}},
+		{"first delayed failure", 3, 5, 15 * time.Minute, last.Add(time.Second)},
+		{"second delayed failure", 4, 5, 15 * time.Minute, last.Add(2 * time.Second)},
+		{"threshold reached", 5, 5, 15 * time.Minute, last.Add(15 * time.Minute)},
+		{"past threshold", 9, 5, 15 * time.Minute, last.Add(15 * time.Minute)},
+		{"last free failure of larger threshold", 10, 20, 15 * time.Minute, time.Time{}},
+		{"doubling delay", 15, 20, 15 * time.Minute, last.Add(16 * time.Second)},
+		{"delay capped at lockout", 17, 20, time.Minute, last.Add(time.Minute)},
+		{"delay overflow capped at lockout", 95, 100, time.Hour, last.Add(time.Hour)},
+		{"single allowed failure", 1, 1, 15 * time.Minute, last.Add(15 * time.Minute)},
+		{"none allowed yet", 0, 1, 15 * time.Minute, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := database.LoginThrottle{Key: "account:a@example.com", Failures: tt.failures, LastFailureAt: last}
+			if got := loginRetryAt(throttle, tt.threshold, tt.lockout); !got.Equal(tt.want) {
+				t.Errorf("loginRetryAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/monitor"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// MaintenanceRequest represents the request body for creating/updating a maintenance window
+type MaintenanceRequest struct {
+	Name        string     `json:"name"`
+	EndpointIDs []int64    `json:"endpoint_ids"`
+	Tags        []string   `json:"tags"`
+	StartsAt    *time.Time `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+	Cron        string     `json:"cron"`
+	Duration    int        `json:"duration"`
+	TimeZone    string     `json:"time_zone"`
+	Mode        string     `json:"mode"`
+	IsActive    *bool      `json:"is_active"`
+}
+
+// MaintenanceResponse is a maintenance window with whether it is in effect right now
+type MaintenanceResponse struct {
+	database.MaintenanceWindow
+	Active bool `json:"active"`
+}
+
+// CreateMaintenanceWindow handles the creation of a new maintenance window
+func CreateMaintenanceWindow(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	orgID := currentOrg(c)
+
+	req := new(MaintenanceRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	window := database.MaintenanceWindow{OrganizationID: orgID, UserID: userID, IsActive: true}
+	applyMaintenanceRequest(&window, req)
+
+	if err := validateMaintenanceWindow(window); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid maintenance window: " + err.Error(),
+		})
+	}
+
+	if err := database.DB.Create(&window).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create maintenance window",
+		})
+	}
+
+	// gorm skips zero values on create, so a disabled window needs a separate update
+	if !window.IsActive {
+		if err := database.DB.Model(&window).Update("is_active", false).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to create maintenance window",
+			})
+		}
+	}
+
+	monitor.PutMaintenanceWindow(window)
+
+	return c.JSON(http.StatusCreated, maintenanceResponse(window))
+}
+
+// GetMaintenanceWindows returns all maintenance windows for the current organization
+func GetMaintenanceWindows(c echo.Context) error {
+	orgID := currentOrg(c)
+
+	var windows []database.MaintenanceWindow
+	if err := database.DB.Where("organization_id = ?", orgID).Order("id").Find(&windows).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch maintenance windows",
+		})
+	}
+
+	response := make([]MaintenanceResponse, len(windows))
+	for i, window := range windows {
+		response[i] = maintenanceResponse(window)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetMaintenanceWindow returns a specific maintenance window by ID
+func GetMaintenanceWindow(c echo.Context) error {
+	window, err := findMaintenanceWindow(c)
+	if err != nil {
+		return lookupError(c, err, "Maintenance window not found")
+	}
+
+	return c.JSON(http.StatusOK, maintenanceResponse(*window))
+}
+
+// UpdateMaintenanceWindow updates an existing maintenance window
+func UpdateMaintenanceWindow(c echo.Context) error {
+	window, err := findMaintenanceWindow(c)
+	if err != nil {
+		return lookupError(c, err, "Maintenance window not found")
+	}
+
+	req := new(MaintenanceRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	applyMaintenanceRequest(window, req)
+
+	if err := validateMaintenanceWindow(*window); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid maintenance window: " + err.Error(),
+		})
+	}
+
+	updates := map[string]interface{}{
+		"name":         window.Name,
+		"endpoint_ids": window.EndpointIDs,
+		"tags":         window.Tags,
+		"starts_at":    window.StartsAt,
+		"ends_at":      window.EndsAt,
+		"cron":         window.Cron,
+		"duration":     window.Duration,
+		"time_zone":    window.TimeZone,
+		"mode":         window.Mode,
+		"is_active":    window.IsActive,
+	}
+
+	if err := database.DB.Model(window).Updates(updates).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update maintenance window",
+		})
+	}
+
+	monitor.PutMaintenanceWindow(*window)
+
+	return c.JSON(http.StatusOK, maintenanceResponse(*window))
+}
+
+// DeleteMaintenanceWindow removes a maintenance window
+func DeleteMaintenanceWindow(c echo.Context) error {
+	window, err := findMaintenanceWindow(c)
+	if err != nil {
+		return lookupError(c, err, "Maintenance window not found")
+	}
+
+	if err := database.DB.Delete(window).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete maintenance window",
+		})
+	}
+
+	monitor.RemoveMaintenanceWindow(window.ID)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// applyMaintenanceRequest copies the fields of a request onto a maintenance window
+func applyMaintenanceRequest(window *database.MaintenanceWindow, req *MaintenanceRequest) {
+	window.Name = strings.TrimSpace(req.Name)
+	window.EndpointIDs = pq.Int64Array(req.EndpointIDs)
+	window.Tags = pq.StringArray(normalizeTags(req.Tags))
+	window.StartsAt = req.StartsAt
+	window.EndsAt = req.EndsAt
+	window.Cron = strings.TrimSpace(req.Cron)
+	window.Duration = req.Duration
+	window.TimeZone = strings.TrimSpace(req.TimeZone)
+	window.Mode = strings.ToLower(strings.TrimSpace(req.Mode))
+	if window.Mode == "" {
+		window.Mode = database.MaintenanceRecord
+	}
+	if req.IsActive != nil {
+		window.IsActive = *req.IsActive
+	}
+}
+
+// validateMaintenanceWindow checks the schedule of a window and that its endpoints belong to its organization
+func validateMaintenanceWindow(window database.MaintenanceWindow) error {
+	if err := monitor.ValidateMaintenanceWindow(window); err != nil {
+		return err
+	}
+
+	if len(window.EndpointIDs) > 0 {
+		var count int64
+		if err := database.DB.Model(&database.Endpoint{}).
+			Where("id IN ? AND organization_id = ?", []int64(window.EndpointIDs), window.OrganizationID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count != int64(len(uniqueIDs(window.EndpointIDs))) {
+			return errors.New("endpoint_ids refers to unknown endpoints")
+		}
+	}
+	return nil
+}
+
+// uniqueIDs returns the distinct IDs of a list
+func uniqueIDs(ids []int64) map[int64]bool {
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// maintenanceResponse adds whether a window is in effect right now
+func maintenanceResponse(window database.MaintenanceWindow) MaintenanceResponse {
+	return MaintenanceResponse{
+		MaintenanceWindow: window,
+		Active:            monitor.MaintenanceActiveAt(window, time.Now()),
+	}
+}
+
+// findMaintenanceWindow loads the maintenance window identified by the id path parameter for the current organization
+func findMaintenanceWindow(c echo.Context) (*database.MaintenanceWindow, error) {
+	orgID := currentOrg(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var window database.MaintenanceWindow
+	if err := database.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&window).Error; err != nil {
+		return nil, err
+	}
+
+	return &window, nil
+}
@@ -0,0 +1,614 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"api-monitor/database"
+	"api-monitor/notifier"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// invitationTTL is how long an invitation can be accepted after it was sent
+const invitationTTL = 7 * 24 * time.Hour
+
+// OrganizationRequest represents the request body for creating/renaming an organization
+type OrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+// OrganizationResponse is an organization with the current user's role in it
+type OrganizationResponse struct {
+	database.Organization
+	Role string `json:"role"`
+}
+
+// InvitationRequest represents the request body for inviting a user to an organization
+type InvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// InvitationResponse is a new invitation along with its token, which is only shown once
+type InvitationResponse struct {
+	database.Invitation
+	Token string `json:"token"`
+}
+
+// CreateOrganization creates an organization with the current user as its owner
+func CreateOrganization(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	req := new(OrganizationRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Name is required",
+		})
+	}
+	if !validOrganizationName(name) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Name must not contain control characters",
+		})
+	}
+
+	var org *database.Organization
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if org, err = database.CreateOrganization(tx, name, false, userID); err != nil {
+			return err
+		}
+		return createTrialSubscription(tx, org.ID, userID)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create organization",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, OrganizationResponse{Organization: *org, Role: database.RoleOwner})
+}
+
+// GetOrganizations returns the organizations the current user is a member of
+func GetOrganizations(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var memberships []database.Membership
+	if err := database.DB.Where("user_id = ?", userID).Order("organization_id").Find(&memberships).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch organizations",
+		})
+	}
+
+	roles := make(map[uint]string, len(memberships))
+	ids := make([]uint, len(memberships))
+	for i, membership := range memberships {
+		roles[membership.OrganizationID] = membership.Role
+		ids[i] = membership.OrganizationID
+	}
+
+	var orgs []database.Organization
+	if len(ids) > 0 {
+		if err := database.DB.Where("id IN ?", ids).Order("id").Find(&orgs).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to fetch organizations",
+			})
+		}
+	}
+
+	response := make([]OrganizationResponse, len(orgs))
+	for i, org := range orgs {
+		response[i] = OrganizationResponse{Organization: org, Role: roles[org.ID]}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// GetOrganization returns an organization the current user is a member of
+func GetOrganization(c echo.Context) error {
+	org, membership, err := findOrganization(c)
+	if err != nil {
+		return lookupError(c, err, "Organization not found")
+	}
+
+	return c.JSON(http.StatusOK, OrganizationResponse{Organization: *org, Role: membership.Role})
+}
+
+// UpdateOrganization renames an organization
+func UpdateOrganization(c echo.Context) error {
+	org, membership, err := findOrganization(c)
+	if err != nil {
+		return lookupError(c, err, "Organization not found")
+	}
+	if !database.RoleAtLeast(membership.Role, database.RoleAdmin) {
+		return forbidden(c, database.RoleAdmin)
+	}
+
+	req := new(OrganizationRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Name is required",
+		})
+	}
+	if !validOrganizationName(name) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Name must not contain control characters",
+		})
+	}
+
+	if err := database.DB.Model(org).Update("name", name).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update organization",
+		})
+	}
+
+	return c.JSON(http.StatusOK, OrganizationResponse{Organization: *org, Role: membership.Role})
+}
+
+// DeleteOrganization removes an organization that no longer has any endpoints
+func DeleteOrganization(c echo.Context) error {
+	org, membership, err := findOrganization(c)
+	if err != nil {
+		return lookupError(c, err, "Organization not found")
+	}
+	if membership.Role != database.RoleOwner {
+		return forbidden(c, database.RoleOwner)
+	}
+
+	if org.Personal {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Personal organizations cannot be deleted",
+		})
+	}
+
+	var endpoints int64
+	if err := database.DB.Model(&database.Endpoint{}).Where("organization_id = ?", org.ID).Count(&endpoints).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete organization",
+		})
+	}
+	if endpoints > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": fmt.Sprintf("Organization still has %d endpoints", endpoints),
+		})
+	}
+
+	if err := database.DeleteOrganization(org); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete organization",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetMembers returns the members of an organization
+func GetMembers(c echo.Context) error {
+	org, _, err := findOrganization(c)
+	if err != nil {
+		return lookupError(c, err, "Organization not found")
+	}
+
+	members, err := database.GetMembers(org.ID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch members",
+		})
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+// UpdateMember changes the role of a member. Only owners may grant or revoke the owner role.
+func UpdateMember(c echo.Context) error {
+	org, membership, err := findOrganization(c)
+	if err != nil {
+		return lookupError(c, err, "Organization not found")
+	}
+	if !database.RoleAtLeast(membership.Role, database.RoleAdmin) {
+		return forbidden(c, database.RoleAdmin)
+	}
+
+	member, err := findMember(c, org.ID)
+	if err != nil {
+		return lookupError(c, err, "Member not found")
+	}
+
+	type RoleRequest struct {
+		Role string `json:"role"`
+	}
+
+	req := new(RoleRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	role := strings.ToLower(strings.TrimSpace(req.Role))
+	if !database.ValidRole(role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Role must be one of owner, admin, editor or viewer",
+		})
+	}
+	if (role == database.RoleOwner || member.Role == database.RoleOwner) && membership.Role != database.RoleOwner {
+		return forbidden(c, database.RoleOwner)
+	}
+
+	if member.Role == database.RoleOwner && role != database.RoleOwner && lastOwner(c, org.ID) {
+		return nil
+	}
+
+	if err := database.DB.Model(member).Update("role", role).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update member",
+		})
+	}
+
+	return c.JSON(http.StatusOK, member)
+}
+
+// RemoveMember removes a member from an organization. Any member may leave an
+// organization; removing others requires the admin role, or owner for owners.
+func RemoveMember(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	org, membership, err := findOrganization(c)
+	if err != nil {
+		return lookupError(c, err, "Organization not found")
+	}
+
+	member, err := findMember(c, org.ID)
+	if err != nil {
+		return lookupError(c, err, "Member not found")
+	}
+
+	if member.UserID != userID {
+		if !database.RoleAtLeast(membership.Role, database.RoleAdmin) {
+			return forbidden(c, database.RoleAdmin)
+		}
+		if member.Role == database.RoleOwner && membership.Role != database.RoleOwner {
+			return forbidden(c, database.RoleOwner)
+		}
+	}
+
+	if org.Personal && member.Role == database.RoleOwner {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Owners cannot be removed from a personal organization",
+		})
+	}
+	if member.Role == database.RoleOwner && lastOwner(c, org.ID) {
+		return nil
+	}
+
+	if err := database.RemoveMembership(member); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to remove member",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// CreateInvitation invites a user by email to join an organization with a role
+// no higher than the inviter's. The token is emailed and returned only once.
+func CreateInvitation(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	org, membership, err := findOrganization(c)
+	if err != nil {
+		return lookupError(c, err, "Organization not found")
+	}
+	if !database.RoleAtLeast(membership.Role, database.RoleAdmin) {
+		return forbidden(c, database.RoleAdmin)
+	}
+
+	req := new(InvitationRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	addr, err := mail.ParseAddress(strings.TrimSpace(req.Email))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid email address",
+		})
+	}
+	email := strings.ToLower(addr.Address)
+
+	role := strings.ToLower(strings.TrimSpace(req.Role))
+	if role == "" {
+		role = database.RoleViewer
+	}
+	if !database.ValidRole(role) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Role must be one of owner, admin, editor or viewer",
+		})
+	}
+	if !database.RoleAtLeast(membership.Role, role) {
+		return forbidden(c, role)
+	}
+
+	var members int64
+	err = database.DB.Model(&database.Membership{}).
+		Joins("JOIN users ON users.id = memberships.user_id").
+		Where("memberships.organization_id = ? AND LOWER(users.email) = ?", org.ID, email).
+		Count(&members).Error
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create invitation",
+		})
+	}
+	if members > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "User is already a member",
+		})
+	}
+
+	token, err := newInvitationToken()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to generate invitation token",
+		})
+	}
+
+	invitation := database.Invitation{
+		OrganizationID: org.ID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      hashInvitationToken(token),
+		InvitedBy:      userID,
+		ExpiresAt:      time.Now().Add(invitationTTL),
+	}
+
+	// A new invitation replaces any pending one for the same address
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("organization_id = ? AND email = ? AND accepted_at IS NULL", org.ID, email).
+			Delete(&database.Invitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&invitation).Error
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create invitation",
+		})
+	}
+
+	// The link points at the configured URL rather than the Host header, which
+	// is chosen by the client. Without one, the inviter passes the token on.
+	if base := appConfig.Server.BaseURL; base != "" {
+		link := strings.TrimRight(base, "/") + "/dashboard?invitation=" + token
+		text := fmt.Sprintf("You have been invited to join %s on API Monitor as %s.\n\n"+
+			"Sign in with %s and open the link below to accept:\n%s\n\n"+
+			"The invitation expires on %s.",
+			org.Name, role, email, link, invitation.ExpiresAt.Format(time.RFC1123))
+		go func() {
+			if err := notifier.SendEmail(email, "Invitation to join "+org.Name, text); err != nil {
+				log.Printf("Failed to send invitation %d: %v", invitation.ID, err)
+			}
+		}()
+	}
+
+	return c.JSON(http.StatusCreated, InvitationResponse{Invitation: invitation, Token: token})
+}
+
+// GetInvitations returns the pending invitations of an organization
+func GetInvitations(c echo.Context) error {
+	org, membership, err := findOrganization(c)
+	if err != nil {
+		return lookupError(c, err, "Organization not found")
+	}
+	if !database.RoleAtLeast(membership.Role, database.RoleAdmin) {
+		return forbidden(c, database.RoleAdmin)
+	}
+
+	var invitations []database.Invitation
+	if err := database.DB.Where("organization_id = ? AND accepted_at IS NULL", org.ID).Order("id").Find(&invitations).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch invitations",
+		})
+	}
+
+	return c.JSON(http.StatusOK, invitations)
+}
+
+// RevokeInvitation removes a pending invitation
+func RevokeInvitation(c echo.Context) error {
+	org, membership, err := findOrganization(c)
+	if err != nil {
+		return lookupError(c, err, "Organization not found")
+	}
+	if !database.RoleAtLeast(membership.Role, database.RoleAdmin) {
+		return forbidden(c, database.RoleAdmin)
+	}
+
+	id, err := strconv.Atoi(c.Param("invitation_id"))
+	if err != nil {
+		return lookupError(c, ErrInvalidID, "")
+	}
+
+	result := database.DB.Where("id = ? AND organization_id = ? AND accepted_at IS NULL", id, org.ID).Delete(&database.Invitation{})
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to revoke invitation",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Invitation not found",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AcceptInvitation makes the current user a member of the organization they
+// were invited to. The invitation must be addressed to the user's email.
+func AcceptInvitation(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	type AcceptRequest struct {
+		Token string `json:"token"`
+	}
+
+	req := new(AcceptRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	var invitation database.Invitation
+	if err := database.DB.Where("token_hash = ?", hashInvitationToken(strings.TrimSpace(req.Token))).First(&invitation).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Invitation not found",
+		})
+	}
+
+	var user database.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "User not found",
+		})
+	}
+	if !strings.EqualFold(user.Email, invitation.Email) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "Invitation was sent to a different email address",
+		})
+	}
+
+	if invitation.AcceptedAt != nil {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Invitation was already accepted",
+		})
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return c.JSON(http.StatusGone, map[string]string{
+			"error": "Invitation has expired",
+		})
+	}
+
+	var org database.Organization
+	if err := database.DB.First(&org, invitation.OrganizationID).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Organization not found",
+		})
+	}
+
+	if _, err := database.GetMembership(org.ID, userID); err == nil {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": "Already a member of this organization",
+		})
+	}
+
+	membership, err := database.AcceptInvitation(&invitation, userID, time.Now())
+	if err != nil {
+		if err == database.ErrInvitationUsed {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": "Invitation was already accepted",
+			})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to accept invitation",
+		})
+	}
+
+	return c.JSON(http.StatusOK, OrganizationResponse{Organization: org, Role: membership.Role})
+}
+
+// lastOwner writes a conflict response and returns true if an organization has
+// no owner besides the one being demoted or removed
+func lastOwner(c echo.Context, orgID uint) bool {
+	owners, err := database.CountOwners(orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to count owners",
+		})
+		return true
+	}
+	if owners <= 1 {
+		c.JSON(http.StatusConflict, map[string]string{
+			"error": "An organization must keep at least one owner",
+		})
+		return true
+	}
+	return false
+}
+
+// validOrganizationName reports whether name is free of control characters,
+// as it is shown in invitation emails and the dashboard
+func validOrganizationName(name string) bool {
+	return strings.IndexFunc(name, unicode.IsControl) == -1
+}
+
+// newInvitationToken generates the secret token of an invitation
+func newInvitationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashInvitationToken returns the stored form of an invitation token
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// findOrganization loads the organization identified by the id path parameter
+// along with the current user's membership in it
+func findOrganization(c echo.Context) (*database.Organization, *database.Membership, error) {
+	userID := c.Get("user_id").(uint)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, nil, ErrInvalidID
+	}
+
+	membership, err := database.GetMembership(uint(id), userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var org database.Organization
+	if err := database.DB.First(&org, membership.OrganizationID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return &org, membership, nil
+}
+
+// findMember loads the membership of the user identified by the user_id path parameter
+func findMember(c echo.Context, orgID uint) (*database.Membership, error) {
+	id, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	return database.GetMembership(orgID, uint(id))
+}
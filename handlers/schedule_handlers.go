@@ -2,128 +2,52 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
+	"sort"
+
+	"api-monitor/database"
+	"api-monitor/scheduler"
 
 	"github.com/labstack/echo/v4"
 )
 
-// ScheduleRequest represents the request body for creating/updating a schedule
-type ScheduleRequest struct {
-	Name      string `json:"name"`
-	Interval  int    `json:"interval"`
-	Endpoints []int  `json:"endpoints"`
+// Schedule groups the scheduled endpoints sharing an interval
+type Schedule struct {
+	Interval  int               `json:"interval"` // in seconds
+	Endpoints []scheduler.Entry `json:"endpoints"`
 }
 
-// CreateScheduleHandler handles the creation of a new schedule
-func CreateScheduleHandler(c echo.Context) error {
-	req := new(ScheduleRequest)
-	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request payload",
-		})
-	}
-
-	schedule, err := CreateSchedule(req.Name, req.Interval, req.Endpoints)
-	if err != nil {
-		if err == ErrEndpointNotFound {
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"error": "One or more endpoints not found",
-			})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to create schedule",
-		})
-	}
-
-	return c.JSON(http.StatusCreated, schedule)
-}
-
-// GetSchedulesHandler returns all schedules
+// GetSchedulesHandler returns the check schedule of the current organization's endpoints, grouped by interval
 func GetSchedulesHandler(c echo.Context) error {
-	schedules := GetSchedules()
-	return c.JSON(http.StatusOK, schedules)
-}
+	orgID := currentOrg(c)
 
-// GetScheduleHandler returns a specific schedule
-func GetScheduleHandler(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid ID format",
-		})
-	}
-
-	schedule, err := GetSchedule(id)
-	if err != nil {
-		if err == ErrScheduleNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": "Schedule not found",
-			})
-		}
+	var ids []int
+	if err := database.DB.Model(&database.Endpoint{}).Where("organization_id = ?", orgID).Pluck("id", &ids).Error; err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to get schedule",
+			"error": "Failed to fetch endpoints",
 		})
 	}
 
-	return c.JSON(http.StatusOK, schedule)
-}
-
-// UpdateScheduleHandler updates an existing schedule
-func UpdateScheduleHandler(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid ID format",
-		})
-	}
-
-	req := new(ScheduleRequest)
-	if err := c.Bind(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request payload",
-		})
-	}
-
-	schedule, err := UpdateSchedule(id, req.Name, req.Interval, req.Endpoints)
-	if err != nil {
-		if err == ErrScheduleNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": "Schedule not found",
-			})
-		}
-		if err == ErrEndpointNotFound {
-			return c.JSON(http.StatusBadRequest, map[string]string{
-				"error": "One or more endpoints not found",
-			})
+	groups := make(map[int]*Schedule)
+	for _, entry := range checkScheduler.Entries(ids) {
+		group, ok := groups[entry.Interval]
+		if !ok {
+			group = &Schedule{Interval: entry.Interval}
+			groups[entry.Interval] = group
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to update schedule",
-		})
+		group.Endpoints = append(group.Endpoints, entry)
 	}
 
-	return c.JSON(http.StatusOK, schedule)
-}
-
-// DeleteScheduleHandler removes a schedule
-func DeleteScheduleHandler(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid ID format",
-		})
+	schedules := make([]*Schedule, 0, len(groups))
+	for _, group := range groups {
+		schedules = append(schedules, group)
 	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].Interval < schedules[j].Interval })
 
-	err = DeleteSchedule(id)
-	if err != nil {
-		if err == ErrScheduleNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{
-				"error": "Schedule not found",
-			})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to delete schedule",
-		})
-	}
+	return c.JSON(http.StatusOK, schedules)
+}
 
-	return c.NoContent(http.StatusNoContent)
+// GetSchedulerStatsHandler returns the load of the check worker pool. As the
+// pool is shared by all organizations, it is only available to operators.
+func GetSchedulerStatsHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, checkScheduler.Stats())
 }
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"api-monitor/config"
+	"api-monitor/scheduler"
+)
+
+var (
+	// appConfig holds the server settings
+	appConfig *config.Config
+
+	// checkScheduler runs the endpoint checks and is kept in sync with endpoint changes
+	checkScheduler *scheduler.Scheduler
+)
+
+// SetConfig sets the server settings used by the handlers
+func SetConfig(cfg *config.Config) {
+	appConfig = cfg
+}
+
+// SetScheduler sets the scheduler that endpoint changes are applied to
+func SetScheduler(s *scheduler.Scheduler) {
+	checkScheduler = s
+}
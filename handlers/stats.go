@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-monitor/database"
+
+	"github.com/labstack/echo/v4"
+)
+
+// statsPeriods are the predefined reporting periods
+var statsPeriods = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// GetEndpointStats returns uptime, latency percentiles and incident statistics for an endpoint.
+// Supports the query parameters period (24h, 7d or 30d), or from and to (RFC 3339),
+// and sla_target to override the endpoint's SLA target.
+func GetEndpointStats(c echo.Context) error {
+	orgID := currentOrg(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid ID format",
+		})
+	}
+
+	from, to, slaTarget, msg := parseReportParams(c)
+	if msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": msg,
+		})
+	}
+
+	var endpoint database.Endpoint
+	if err := database.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&endpoint).Error; err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Endpoint not found",
+		})
+	}
+
+	stats, err := database.GetEndpointStats(&endpoint, from, to, slaTarget)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to compute endpoint statistics",
+		})
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// GetSLAReport summarises uptime and SLA compliance across all of the current organization's endpoints.
+// Accepts the same query parameters as GetEndpointStats.
+func GetSLAReport(c echo.Context) error {
+	orgID := currentOrg(c)
+
+	from, to, slaTarget, msg := parseReportParams(c)
+	if msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": msg,
+		})
+	}
+
+	var dbEndpoints []database.Endpoint
+	if err := database.DB.Where("organization_id = ?", orgID).Order("id").Find(&dbEndpoints).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch endpoints",
+		})
+	}
+
+	type SLAReport struct {
+		From         time.Time                 `json:"from"`
+		To           time.Time                 `json:"to"`
+		Uptime       float64                   `json:"uptime"` // Over all checks of all endpoints
+		TotalChecks  int64                     `json:"total_checks"`
+		FailedChecks int64                     `json:"failed_checks"`
+		Incidents    int64                     `json:"incidents"`
+		Downtime     int64                     `json:"downtime"` // in seconds
+		Breaches     int                       `json:"breaches"` // Endpoints below their SLA target
+		Endpoints    []*database.EndpointStats `json:"endpoints"`
+	}
+
+	report := SLAReport{
+		From:   from,
+		To:     to,
+		Uptime: 100,
+	}
+
+	stats, err := database.GetEndpointsStats(dbEndpoints, from, to, slaTarget)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to compute endpoint statistics",
+		})
+	}
+
+	for _, endpointStats := range stats {
+		report.TotalChecks += endpointStats.TotalChecks
+		report.FailedChecks += endpointStats.FailedChecks
+		report.Incidents += endpointStats.Incidents
+		report.Downtime += endpointStats.Downtime
+		if endpointStats.SLABreached {
+			report.Breaches++
+		}
+	}
+	report.Endpoints = stats
+
+	if report.TotalChecks > 0 {
+		report.Uptime = float64(report.TotalChecks-report.FailedChecks) / float64(report.TotalChecks) * 100
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// parseReportParams reads the reporting range and SLA target override from the query.
+// It returns a non-empty message if a parameter is invalid.
+func parseReportParams(c echo.Context) (from, to time.Time, slaTarget float64, msg string) {
+	to = time.Now()
+
+	if v := c.QueryParam("sla_target"); v != "" {
+		target, err := strconv.ParseFloat(v, 64)
+		if err != nil || target <= 0 || target > 100 {
+			return from, to, 0, "Invalid sla_target, must be a percentage"
+		}
+		slaTarget = target
+	}
+
+	fromParam, toParam := c.QueryParam("from"), c.QueryParam("to")
+	if fromParam == "" && toParam == "" {
+		period := c.QueryParam("period")
+		if period == "" {
+			period = "24h"
+		}
+		duration, ok := statsPeriods[period]
+		if !ok {
+			return from, to, 0, "Invalid period, must be one of 24h, 7d or 30d"
+		}
+		return to.Add(-duration), to, slaTarget, ""
+	}
+
+	var err error
+	if fromParam == "" {
+		return from, to, 0, "from is required for a custom range"
+	}
+	if from, err = time.Parse(time.RFC3339, fromParam); err != nil {
+		return from, to, 0, "Invalid from timestamp, expected RFC 3339"
+	}
+	if toParam != "" {
+		if to, err = time.Parse(time.RFC3339, toParam); err != nil {
+			return from, to, 0, "Invalid to timestamp, expected RFC 3339"
+		}
+	}
+	if !from.Before(to) {
+		return from, to, 0, "from must be before to"
+	}
+
+	return from, to, slaTarget, ""
+}
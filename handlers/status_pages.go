@@ -0,0 +1,500 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/monitor"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Public statuses of endpoints, components and pages, from least to most severe
+const (
+	statusUnknown     = "unknown"
+	statusOperational = "operational"
+	statusMaintenance = "maintenance"
+	statusDegraded    = "degraded"
+	statusOutage      = "outage"
+)
+
+var statusSeverity = map[string]int{
+	statusUnknown:     0,
+	statusOperational: 1,
+	statusMaintenance: 2,
+	statusDegraded:    3,
+	statusOutage:      4,
+}
+
+const (
+	statusPageDays          = 90               // Days of uptime bars
+	statusPageIncidentDays  = 14               // Days resolved incidents stay listed
+	statusPageCacheDuration = 60 * time.Second // How long a rendered page is served from memory
+	maxStatusPageComponents = 50               // Components per page
+)
+
+// statusPageSlug matches lowercase slugs of letters, digits and single dashes
+var statusPageSlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// StatusPageRequest represents the request body for creating/updating a status page
+type StatusPageRequest struct {
+	Slug        string                     `json:"slug"`
+	Title       string                     `json:"title"`
+	Description string                     `json:"description"`
+	Components  []database.StatusComponent `json:"components"`
+}
+
+// PublicStatusPage is the public view of a status page. It only contains display
+// names, never endpoint URLs or check errors.
+type PublicStatusPage struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Status      string            `json:"status"` // Most severe status of its components
+	Components  []PublicComponent `json:"components"`
+	Incidents   []PublicIncident  `json:"incidents"` // Unresolved and recently resolved, newest first
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// PublicComponent is a component of a public status page
+type PublicComponent struct {
+	Name      string           `json:"name"`
+	Status    string           `json:"status"`
+	Endpoints []PublicEndpoint `json:"endpoints"`
+}
+
+// PublicEndpoint is an endpoint of a public status page with its daily uptime
+type PublicEndpoint struct {
+	Name   string      `json:"name"`
+	Status string      `json:"status"`
+	Uptime *float64    `json:"uptime"` // Percentage over all days, nil without checks
+	Days   []PublicDay `json:"days"`   // Oldest first, ending today (UTC)
+}
+
+// PublicDay is the uptime of an endpoint on a single UTC day
+type PublicDay struct {
+	Date   string   `json:"date"`
+	Uptime *float64 `json:"uptime"` // nil if there were no checks
+}
+
+// PublicIncident is an incident of an endpoint on a public status page
+type PublicIncident struct {
+	Name       string     `json:"name"` // Display name of the endpoint
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	Duration   int64      `json:"duration"` // in seconds, set once resolved
+}
+
+// cachedStatusPage is a rendered public status page
+type cachedStatusPage struct {
+	page       *PublicStatusPage
+	renderedAt time.Time
+}
+
+// statusPageCache keeps rendered pages so that public traffic does not hit the database on every request
+var statusPageCache = struct {
+	sync.Mutex
+	pages map[string]cachedStatusPage
+}{pages: make(map[string]cachedStatusPage)}
+
+// CreateStatusPage handles the creation of a new status page
+func CreateStatusPage(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	orgID := currentOrg(c)
+
+	req := new(StatusPageRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	page := database.StatusPage{OrganizationID: orgID, UserID: userID}
+	applyStatusPageRequest(&page, req)
+
+	if err := validateStatusPage(page); err != nil {
+		return statusPageValidationError(c, err)
+	}
+
+	if err := database.DB.Create(&page).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create status page",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, page)
+}
+
+// GetStatusPages returns all status pages for the current organization
+func GetStatusPages(c echo.Context) error {
+	orgID := currentOrg(c)
+
+	var pages []database.StatusPage
+	if err := database.DB.Where("organization_id = ?", orgID).Order("id").Find(&pages).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch status pages",
+		})
+	}
+
+	return c.JSON(http.StatusOK, pages)
+}
+
+// GetStatusPage returns a specific status page by ID
+func GetStatusPage(c echo.Context) error {
+	page, err := findStatusPage(c)
+	if err != nil {
+		return lookupError(c, err, "Status page not found")
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// UpdateStatusPage updates an existing status page
+func UpdateStatusPage(c echo.Context) error {
+	page, err := findStatusPage(c)
+	if err != nil {
+		return lookupError(c, err, "Status page not found")
+	}
+
+	req := new(StatusPageRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	oldSlug := page.Slug
+	applyStatusPageRequest(page, req)
+
+	if err := validateStatusPage(*page); err != nil {
+		return statusPageValidationError(c, err)
+	}
+
+	// Map updates skip the json serializer, so update from the struct
+	if err := database.DB.Model(page).Select("slug", "title", "description", "components").Updates(page).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to update status page",
+		})
+	}
+
+	forgetStatusPage(oldSlug)
+	forgetStatusPage(page.Slug)
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// DeleteStatusPage removes a status page
+func DeleteStatusPage(c echo.Context) error {
+	page, err := findStatusPage(c)
+	if err != nil {
+		return lookupError(c, err, "Status page not found")
+	}
+
+	// Deleted permanently so that the slug can be used again
+	if err := database.DB.Unscoped().Delete(page).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to delete status page",
+		})
+	}
+
+	forgetStatusPage(page.Slug)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ServeStatusPage serves the public status page with the slug in the path. It
+// returns JSON when requested through the Accept header or format=json, and the
+// HTML page otherwise. No authentication is required.
+func ServeStatusPage(c echo.Context) error {
+	wantsJSON := c.QueryParam("format") == "json" ||
+		strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMEApplicationJSON)
+
+	page, err := publicStatusPage(c.Param("slug"))
+	if err != nil {
+		status, message := http.StatusInternalServerError, "Failed to load status page"
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status, message = http.StatusNotFound, "Status page not found"
+		}
+		if wantsJSON {
+			return c.JSON(status, map[string]string{
+				"error": message,
+			})
+		}
+		return c.String(status, message)
+	}
+
+	c.Response().Header().Set(echo.HeaderVary, echo.HeaderAccept)
+	c.Response().Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(statusPageCacheDuration.Seconds())))
+	if wantsJSON {
+		return c.JSON(http.StatusOK, page)
+	}
+	return c.File("static/status.html")
+}
+
+// publicStatusPage returns the rendered status page with the given slug, from the cache if fresh
+func publicStatusPage(slug string) (*PublicStatusPage, error) {
+	statusPageCache.Lock()
+	cached, ok := statusPageCache.pages[slug]
+	statusPageCache.Unlock()
+	if ok && time.Since(cached.renderedAt) < statusPageCacheDuration {
+		return cached.page, nil
+	}
+
+	page, err := database.GetStatusPageBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	public, err := renderStatusPage(page, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	statusPageCache.Lock()
+	statusPageCache.pages[slug] = cachedStatusPage{page: public, renderedAt: time.Now()}
+	statusPageCache.Unlock()
+	return public, nil
+}
+
+// forgetStatusPage drops a rendered page from the cache
+func forgetStatusPage(slug string) {
+	statusPageCache.Lock()
+	defer statusPageCache.Unlock()
+	delete(statusPageCache.pages, slug)
+}
+
+// renderStatusPage builds the public view of a status page
+func renderStatusPage(page *database.StatusPage, now time.Time) (*PublicStatusPage, error) {
+	ids := page.EndpointIDs()
+
+	var endpoints []database.Endpoint
+	if len(ids) > 0 {
+		if err := database.DB.Where("id IN ? AND organization_id = ?", ids, page.OrganizationID).Find(&endpoints).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[int]database.Endpoint, len(endpoints))
+	for _, e := range endpoints {
+		byID[int(e.ID)] = e
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -(statusPageDays - 1))
+	daily, err := database.GetDailyUptime(ids, from)
+	if err != nil {
+		return nil, err
+	}
+	uptimes := make(map[int]map[string]database.DailyUptime)
+	for _, d := range daily {
+		if uptimes[d.EndpointID] == nil {
+			uptimes[d.EndpointID] = make(map[string]database.DailyUptime)
+		}
+		uptimes[d.EndpointID][d.Day] = d
+	}
+
+	incidents, err := database.GetStatusPageIncidents(ids, now.AddDate(0, 0, -statusPageIncidentDays))
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string)
+	unresolved := make(map[int]bool)
+	for _, incident := range incidents {
+		if incident.Status != database.IncidentResolved {
+			unresolved[incident.EndpointID] = true
+		}
+	}
+
+	public := &PublicStatusPage{
+		Title:       page.Title,
+		Description: page.Description,
+		Status:      statusUnknown,
+		Components:  make([]PublicComponent, 0, len(page.Components)),
+		Incidents:   make([]PublicIncident, 0, len(incidents)),
+		UpdatedAt:   now,
+	}
+
+	for _, component := range page.Components {
+		pc := PublicComponent{Name: component.Name, Status: statusUnknown}
+		for _, pe := range component.Endpoints {
+			endpoint, ok := byID[pe.EndpointID]
+			if !ok {
+				continue
+			}
+			names[pe.EndpointID] = pe.DisplayName
+
+			e := PublicEndpoint{
+				Name:   pe.DisplayName,
+				Status: publicStatus(endpoint, unresolved[pe.EndpointID]),
+				Days:   make([]PublicDay, 0, statusPageDays),
+			}
+			var total, failed int64
+			for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+				date := day.Format("2006-01-02")
+				d, ok := uptimes[pe.EndpointID][date]
+				pd := PublicDay{Date: date}
+				if ok && d.TotalChecks > 0 {
+					pd.Uptime = percentage(d.TotalChecks-d.FailedChecks, d.TotalChecks)
+					total += d.TotalChecks
+					failed += d.FailedChecks
+				}
+				e.Days = append(e.Days, pd)
+			}
+			if total > 0 {
+				e.Uptime = percentage(total-failed, total)
+			}
+
+			pc.Status = worseStatus(pc.Status, e.Status)
+			pc.Endpoints = append(pc.Endpoints, e)
+		}
+		public.Status = worseStatus(public.Status, pc.Status)
+		public.Components = append(public.Components, pc)
+	}
+
+	for _, incident := range incidents {
+		// Skip incidents of endpoints that are no longer shown
+		if _, ok := names[incident.EndpointID]; !ok {
+			continue
+		}
+		public.Incidents = append(public.Incidents, PublicIncident{
+			Name:       names[incident.EndpointID],
+			Status:     incident.Status,
+			StartedAt:  incident.StartedAt,
+			ResolvedAt: incident.ResolvedAt,
+			Duration:   incident.Duration,
+		})
+	}
+
+	return public, nil
+}
+
+// publicStatus maps the status of an endpoint to its public status
+func publicStatus(endpoint database.Endpoint, incidentOpen bool) string {
+	switch {
+	case incidentOpen:
+		return statusOutage
+	case endpoint.Status == monitor.StatusMaintenance:
+		return statusMaintenance
+	case endpoint.Status == monitor.ResultError || endpoint.Status == monitor.StatusFlapping:
+		return statusDegraded
+	case endpoint.Status == monitor.ResultOK || endpoint.Status == monitor.StatusWarning:
+		return statusOperational
+	default:
+		return statusUnknown
+	}
+}
+
+// worseStatus returns the more severe of two public statuses
+func worseStatus(a, b string) string {
+	if statusSeverity[b] > statusSeverity[a] {
+		return b
+	}
+	return a
+}
+
+// percentage returns part as a percentage of total
+func percentage(part, total int64) *float64 {
+	p := float64(part) / float64(total) * 100
+	return &p
+}
+
+// errSlugTaken is returned when another status page uses the requested slug
+var errSlugTaken = errors.New("slug is already taken")
+
+// applyStatusPageRequest copies the fields of a request onto a status page
+func applyStatusPageRequest(page *database.StatusPage, req *StatusPageRequest) {
+	page.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+	page.Title = strings.TrimSpace(req.Title)
+	page.Description = strings.TrimSpace(req.Description)
+	page.Components = req.Components
+	for i := range page.Components {
+		page.Components[i].Name = strings.TrimSpace(page.Components[i].Name)
+		for j := range page.Components[i].Endpoints {
+			e := &page.Components[i].Endpoints[j]
+			e.DisplayName = strings.TrimSpace(e.DisplayName)
+		}
+	}
+}
+
+// validateStatusPage checks the slug and components of a page and that its endpoints belong to its organization
+func validateStatusPage(page database.StatusPage) error {
+	if len(page.Slug) < 3 || len(page.Slug) > 64 || !statusPageSlug.MatchString(page.Slug) {
+		return errors.New("slug must be 3 to 64 lowercase letters, digits and dashes")
+	}
+	if page.Title == "" {
+		return errors.New("title is required")
+	}
+	if len(page.Components) == 0 || len(page.Components) > maxStatusPageComponents {
+		return fmt.Errorf("a page needs between 1 and %d components", maxStatusPageComponents)
+	}
+
+	seen := make(map[int]bool)
+	for i, component := range page.Components {
+		if component.Name == "" {
+			return fmt.Errorf("component %d needs a name", i+1)
+		}
+		if len(component.Endpoints) == 0 {
+			return fmt.Errorf("component %q needs at least one endpoint", component.Name)
+		}
+		for _, e := range component.Endpoints {
+			if e.DisplayName == "" {
+				return fmt.Errorf("component %q: endpoint %d needs a display_name", component.Name, e.EndpointID)
+			}
+			if seen[e.EndpointID] {
+				return fmt.Errorf("endpoint %d is listed more than once", e.EndpointID)
+			}
+			seen[e.EndpointID] = true
+		}
+	}
+
+	ids := page.EndpointIDs()
+	var count int64
+	if err := database.DB.Model(&database.Endpoint{}).Where("id IN ? AND organization_id = ?", ids, page.OrganizationID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count != int64(len(ids)) {
+		return errors.New("components refer to unknown endpoints")
+	}
+
+	var taken int64
+	if err := database.DB.Model(&database.StatusPage{}).Where("slug = ? AND id <> ?", page.Slug, page.ID).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return errSlugTaken
+	}
+	return nil
+}
+
+// statusPageValidationError writes the response for a status page that failed validation
+func statusPageValidationError(c echo.Context, err error) error {
+	status := http.StatusBadRequest
+	if err == errSlugTaken {
+		status = http.StatusConflict
+	}
+	return c.JSON(status, map[string]string{
+		"error": "Invalid status page: " + err.Error(),
+	})
+}
+
+// findStatusPage loads the status page identified by the id path parameter for the current organization
+func findStatusPage(c echo.Context) (*database.StatusPage, error) {
+	orgID := currentOrg(c)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var page database.StatusPage
+	if err := database.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&page).Error; err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
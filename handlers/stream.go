@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"api-monitor/broker"
+
+	"github.com/labstack/echo/v4"
+)
+
+// streamKeepAlive is how often a comment is sent on an idle stream so that proxies keep it open
+const streamKeepAlive = 30 * time.Second
+
+// Stream pushes check results, status changes and incident changes of the current
+// organization's endpoints as Server-Sent Events. The stream ends when the client
+// disconnects, falls too far behind or the server shuts down; clients should
+// then reload the endpoints and reconnect.
+func Stream(c echo.Context) error {
+	orgID := currentOrg(c)
+
+	events, unsubscribe := broker.Subscribe(orgID)
+	defer unsubscribe()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Failed to encode %s event: %v", event.Type, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
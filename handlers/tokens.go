@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/middleware"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// maxAPITokens limits the number of unrevoked API tokens of a user
+const maxAPITokens = 50
+
+// APITokenRequest represents the request body for creating an API token
+type APITokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APITokenResponse is a new API token along with its secret, which is only shown once
+type APITokenResponse struct {
+	database.APIToken
+	Token string `json:"token"`
+}
+
+// CreateAPIToken creates a personal API token for the current user
+func CreateAPIToken(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	req := new(APITokenRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request payload",
+		})
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Name is required",
+		})
+	}
+
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "expires_at must be in the future",
+		})
+	}
+
+	var count int64
+	if err := database.DB.Model(&database.APIToken{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create token",
+		})
+	}
+	if count >= maxAPITokens {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": "Token limit reached, revoke unused tokens first",
+		})
+	}
+
+	secret, err := middleware.NewAPIToken()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to generate token",
+		})
+	}
+
+	token := database.APIToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:len(middleware.APITokenPrefix)+8],
+		TokenHash: middleware.HashToken(secret),
+		Scopes:    pq.StringArray(scopes),
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	if err := database.DB.Create(&token).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to create token",
+		})
+	}
+
+	return c.JSON(http.StatusCreated, APITokenResponse{APIToken: token, Token: secret})
+}
+
+// GetAPITokens returns the unrevoked API tokens of the current user, without their secrets
+func GetAPITokens(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	var tokens []database.APIToken
+	if err := database.DB.Where("user_id = ?", userID).Order("id").Find(&tokens).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch tokens",
+		})
+	}
+
+	return c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeAPIToken revokes an API token of the current user, effective immediately
+func RevokeAPIToken(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return lookupError(c, ErrInvalidID, "")
+	}
+
+	result := database.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&database.APIToken{})
+	if result.Error != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to revoke token",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Token not found",
+		})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// normalizeScopes checks and deduplicates the scopes of a token request
+func normalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	var result []string
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !database.ValidScope(scope) {
+			return nil, fmt.Errorf("unknown scope %q, must be one of %s", scope, strings.Join(database.Scopes, ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	return result, nil
+}
@@ -6,11 +6,13 @@ import (
 	"time"
 
 	"api-monitor/database"
+	"api-monitor/notifier"
 
 	"log"
 
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // CreateUser handles user registration
@@ -43,35 +45,75 @@ func CreateUser(c echo.Context) error {
 		IsActive: true,
 	}
 
-	if err := database.DB.Create(user).Error; err != nil {
-		if strings.Contains(err.Error(), "uni_users_email") {
-			return c.JSON(http.StatusConflict, map[string]string{
-				"error": "Email already registered",
+	// Registration attempts are counted like failed logins, by account and by address
+	notify := registrationAllowed(c, req.Email)
+
+	// Create the user with a personal organization on the default plan
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		org, err := database.CreatePersonalOrganization(tx, user)
+		if err != nil {
+			return err
+		}
+		return createTrialSubscription(tx, org.ID, user.ID)
+	})
+	if err != nil {
+		if !strings.Contains(err.Error(), "uni_users_email") {
+			log.Printf("Failed to create user: %v", err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{
+				"error": "Failed to create user",
 			})
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to create user",
-		})
+
+		// Respond as for a new account so that registration does not reveal
+		// which emails exist, and let the owner of the address know instead
+		if notify {
+			go notifyExistingAccount(req.Email)
+		}
 	}
 
-	// Create default subscription
-	subscription := &database.Subscription{
-		UserID:           user.ID,
-		PlanName:         "Free",
-		MaxEndpoints:     5,
-		AllowedIntervals: []int64{5, 60, 300, 900, 1800}, // 5sec, 1min, 5min, 15min, 30min in seconds
-		IsActive:         true,
-		ExpiresAt:        time.Now().AddDate(0, 1, 0), // 1 month trial
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": "Registration received, sign in to continue",
+	})
+}
+
+// registrationAllowed counts a registration attempt by account and by address,
+// and reports whether it is within the limits. Registering an existing email
+// only notifies its owner when it is, so that registration cannot be used to
+// send an unbounded number of emails.
+func registrationAllowed(c echo.Context, email string) bool {
+	retryAt, err := newRegistrationThrottle(email, c.RealIP()).count()
+	if err != nil {
+		log.Printf("Failed to count registration attempt for %s: %v", email, err)
+		return false
 	}
+	return !time.Now().Before(retryAt)
+}
 
-	if err := database.DB.Create(subscription).Error; err != nil {
-		log.Printf("Failed to create subscription: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to create subscription",
-		})
+// notifyExistingAccount emails the owner of an address that someone tried to register with it
+func notifyExistingAccount(email string) {
+	text := "Someone tried to create an API Monitor account with this email address, which already has one.\n\n" +
+		"If this was you, sign in instead. Otherwise you can ignore this message."
+	if err := notifier.SendEmail(email, "Registration attempt for your account", text); err != nil {
+		log.Printf("Failed to notify existing account of registration attempt: %v", err)
 	}
+}
 
-	return c.JSON(http.StatusCreated, user)
+// createTrialSubscription gives an organization the default plan for its trial period
+func createTrialSubscription(tx *gorm.DB, orgID, userID uint) error {
+	plan := appConfig.Subscription
+	subscription := &database.Subscription{
+		OrganizationID:   orgID,
+		UserID:           userID,
+		PlanName:         plan.PlanName,
+		MaxEndpoints:     plan.MaxEndpoints,
+		AllowedIntervals: plan.AllowedIntervals,
+		IsActive:         true,
+		ExpiresAt:        time.Now().AddDate(0, 0, plan.TrialDays),
+	}
+	return tx.Create(subscription).Error
 }
 
 // GetUser retrieves user information
@@ -127,12 +169,12 @@ func UpdateUser(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
-// GetSubscription retrieves user's subscription information
+// GetSubscription retrieves the subscription information of the current organization
 func GetSubscription(c echo.Context) error {
-	userID := c.Get("user_id").(uint)
+	orgID := currentOrg(c)
 
 	var subscription database.Subscription
-	if err := database.DB.Where("user_id = ?", userID).First(&subscription).Error; err != nil {
+	if err := database.DB.Where("organization_id = ?", orgID).First(&subscription).Error; err != nil {
 		return c.JSON(http.StatusNotFound, map[string]string{
 			"error": "Subscription not found",
 		})
@@ -140,3 +182,17 @@ func GetSubscription(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, subscription)
 }
+
+// GetFailedLogins returns the recent failed logins to the current user's account
+func GetFailedLogins(c echo.Context) error {
+	userID := c.Get("user_id").(uint)
+
+	failures, err := database.GetFailedLogins(userID, 100)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to fetch failed logins",
+		})
+	}
+
+	return c.JSON(http.StatusOK, failures)
+}
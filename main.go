@@ -1,58 +1,71 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"api-monitor/broker"
+	"api-monitor/config"
 	"api-monitor/database"
 	"api-monitor/handlers"
 	"api-monitor/middleware"
-	"api-monitor/models"
+	"api-monitor/monitor"
+	"api-monitor/notifier"
+	"api-monitor/scheduler"
 
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
-	"github.com/lib/pq"
 )
 
-// Endpoint represents an API endpoint to monitor
-type Endpoint struct {
-	ID          int       `json:"id"`
-	URL         string    `json:"url"`
-	Interval    int       `json:"interval"` // in seconds
-	LastChecked time.Time `json:"last_checked"`
-	Status      string    `json:"status"`
-}
-
-// HealthCheck represents a health check result
-type HealthCheck struct {
-	ID         int       `json:"id"`
-	EndpointID int       `json:"endpoint_id"`
-	Status     int       `json:"status"`
-	Response   string    `json:"response"`
-	CheckedAt  time.Time `json:"checked_at"`
-}
-
-var endpoints []Endpoint
+func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML config file")
+	flag.Parse()
 
-// Track consecutive failures for each endpoint
-var consecutiveFailures = make(map[int]int)
+	// Load settings
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	handlers.SetConfig(cfg)
+	monitor.Configure(cfg.Checker)
+	notifier.SetSMTPConfig(notifier.SMTPConfig(cfg.SMTP))
 
-func main() {
 	// Initialize database
-	if err := database.InitDB(); err != nil {
+	if err := database.InitDB(cfg.Database); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	// Load endpoints from database
-	if err := handlers.LoadEndpoints(); err != nil {
+	if err := monitor.LoadMaintenanceWindows(); err != nil {
+		log.Fatalf("Failed to load maintenance windows: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Load endpoints from database into the scheduler
+	checkScheduler := scheduler.New(monitor.CheckEndpoint, monitor.RecordSkipped, scheduler.Config{
+		Workers:    cfg.Checker.Workers,
+		QueueSize:  cfg.Checker.QueueSize,
+		MaxPerHost: cfg.Checker.MaxPerHost,
+	})
+	if err := checkScheduler.LoadEndpoints(); err != nil {
 		log.Fatalf("Failed to load endpoints: %v", err)
 	}
+	handlers.SetScheduler(checkScheduler)
 
 	// Initialize Echo
 	e := echo.New()
 
+	// Client addresses, used to throttle logins, are only taken from
+	// X-Forwarded-For when set by a configured trusted proxy
+	e.IPExtractor = ipExtractor(cfg.Server)
+
 	// Middleware
 	e.Use(echomiddleware.Logger())
 	e.Use(echomiddleware.Recover())
@@ -78,167 +91,210 @@ func main() {
 	// Public API routes
 	e.POST("/register", handlers.CreateUser)
 	e.POST("/login", handlers.Login)
+	e.POST("/refresh", handlers.Refresh)
+
+	// Public status pages, HTML or JSON depending on the Accept header
+	e.GET("/status/:slug", handlers.ServeStatusPage)
+
+	// Heartbeat pings, authenticated by the token in the path
+	e.POST("/hb/:token", handlers.Heartbeat)
 
 	// Protected API routes
 	api := e.Group("/api")
-	api.Use(middleware.JWT([]byte("your-secret-key"))) // Replace with your secret key
+	api.Use(middleware.JWT([]byte(cfg.Auth.JWTSecret)))
+	api.Use(middleware.Organization())
+
+	// Changes to an organization's resources require the editor role, reads any membership
+	editor := handlers.RequireRole(database.RoleEditor)
 
 	// User routes
 	api.GET("/user", handlers.GetUser)
 	api.PUT("/user", handlers.UpdateUser)
 	api.GET("/subscription", handlers.GetSubscription)
+	api.GET("/user/failed-logins", handlers.GetFailedLogins)
+
+	// Session routes
+	api.POST("/logout", handlers.Logout)
+	api.POST("/logout/all", handlers.LogoutAll)
+
+	// Personal API token routes, only available to signed-in sessions
+	api.POST("/tokens", handlers.CreateAPIToken)
+	api.GET("/tokens", handlers.GetAPITokens)
+	api.DELETE("/tokens/:id", handlers.RevokeAPIToken)
 
 	// Endpoint routes
-	api.POST("/endpoints", handlers.CreateEndpoint)
+	api.POST("/endpoints", handlers.CreateEndpoint, editor)
 	api.GET("/endpoints", handlers.GetEndpoints)
 	api.GET("/endpoints/:id", handlers.GetEndpoint)
-	api.PUT("/endpoints/:id", handlers.UpdateEndpoint)
-	api.DELETE("/endpoints/:id", handlers.DeleteEndpoint)
+	api.PUT("/endpoints/:id", handlers.UpdateEndpoint, editor)
+	api.DELETE("/endpoints/:id", handlers.DeleteEndpoint, editor)
+	api.GET("/endpoints/:id/checks", handlers.GetEndpointChecks)
+	api.GET("/endpoints/:id/stats", handlers.GetEndpointStats)
+
+	// Report routes
+	api.GET("/reports/sla", handlers.GetSLAReport)
+
+	// Certificate routes
+	api.GET("/certificates", handlers.GetCertificates)
+
+	// Notification channel routes
+	api.POST("/channels", handlers.CreateChannel, editor)
+	api.GET("/channels", handlers.GetChannels)
+	api.GET("/channels/:id", handlers.GetChannel)
+	api.PUT("/channels/:id", handlers.UpdateChannel, editor)
+	api.DELETE("/channels/:id", handlers.DeleteChannel, editor)
+	api.POST("/channels/:id/test", handlers.TestChannel, editor)
+
+	// Incident routes
+	api.GET("/incidents", handlers.GetIncidents)
+	api.GET("/incidents/:id", handlers.GetIncident)
+	api.POST("/incidents/:id/ack", handlers.AcknowledgeIncident, editor)
+
+	// Maintenance window routes
+	api.POST("/maintenance", handlers.CreateMaintenanceWindow, editor)
+	api.GET("/maintenance", handlers.GetMaintenanceWindows)
+	api.GET("/maintenance/:id", handlers.GetMaintenanceWindow)
+	api.PUT("/maintenance/:id", handlers.UpdateMaintenanceWindow, editor)
+	api.DELETE("/maintenance/:id", handlers.DeleteMaintenanceWindow, editor)
+
+	// Escalation policy routes
+	api.POST("/escalation-policies", handlers.CreateEscalationPolicy, editor)
+	api.GET("/escalation-policies", handlers.GetEscalationPolicies)
+	api.GET("/escalation-policies/:id", handlers.GetEscalationPolicy)
+	api.PUT("/escalation-policies/:id", handlers.UpdateEscalationPolicy, editor)
+	api.DELETE("/escalation-policies/:id", handlers.DeleteEscalationPolicy, editor)
+
+	// Status page routes
+	api.POST("/status-pages", handlers.CreateStatusPage, editor)
+	api.GET("/status-pages", handlers.GetStatusPages)
+	api.GET("/status-pages/:id", handlers.GetStatusPage)
+	api.PUT("/status-pages/:id", handlers.UpdateStatusPage, editor)
+	api.DELETE("/status-pages/:id", handlers.DeleteStatusPage, editor)
+
+	// Organization routes, roles are checked against the organization in the path
+	api.POST("/organizations", handlers.CreateOrganization)
+	api.GET("/organizations", handlers.GetOrganizations)
+	api.GET("/organizations/:id", handlers.GetOrganization)
+	api.PUT("/organizations/:id", handlers.UpdateOrganization)
+	api.DELETE("/organizations/:id", handlers.DeleteOrganization)
+	api.GET("/organizations/:id/members", handlers.GetMembers)
+	api.PUT("/organizations/:id/members/:user_id", handlers.UpdateMember)
+	api.DELETE("/organizations/:id/members/:user_id", handlers.RemoveMember)
+	api.POST("/organizations/:id/invitations", handlers.CreateInvitation)
+	api.GET("/organizations/:id/invitations", handlers.GetInvitations)
+	api.DELETE("/organizations/:id/invitations/:invitation_id", handlers.RevokeInvitation)
+	api.POST("/invitations/accept", handlers.AcceptInvitation)
+
+	// Live updates
+	api.GET("/stream", handlers.Stream)
 
 	// Schedule routes
-	api.POST("/schedules", handlers.CreateScheduleHandler)
 	api.GET("/schedules", handlers.GetSchedulesHandler)
-	api.GET("/schedules/:id", handlers.GetScheduleHandler)
-	api.PUT("/schedules/:id", handlers.UpdateScheduleHandler)
-	api.DELETE("/schedules/:id", handlers.DeleteScheduleHandler)
+	api.GET("/scheduler/stats", handlers.GetSchedulerStatsHandler, handlers.RequireOperator())
 
 	// Start health monitoring in background
-	go startHealthMonitoring()
+	go checkScheduler.Run(ctx)
 
 	// Start expiry checker in background
-	go startExpiryChecker()
+	go startExpiryChecker(ctx)
 
-	// Start server
-	e.Logger.Fatal(e.Start(":8080"))
-}
-
-func startHealthMonitoring() {
-	for {
-		// Load all non-expired endpoints from database
-		var dbEndpoints []database.Endpoint
-		if err := database.DB.Where("expires_at > ? OR expires_at IS NULL", time.Now()).Find(&dbEndpoints).Error; err != nil {
-			log.Printf("Failed to load endpoints: %v", err)
-			time.Sleep(30 * time.Second)
-			continue
-		}
+	// Start escalation of unacknowledged incidents in background
+	go startEscalationChecker(ctx)
 
-		log.Printf("Starting health monitoring for %d endpoints", len(dbEndpoints))
+	// Start removal of expired sessions and login records in background
+	go startSessionCleanup(ctx, cfg.Auth.LoginLockout)
 
-		// Group endpoints by interval
-		intervalGroups := make(map[int][]database.Endpoint)
-		for _, endpoint := range dbEndpoints {
-			intervalGroups[endpoint.Interval] = append(intervalGroups[endpoint.Interval], endpoint)
+	// Start server
+	go func() {
+		var err error
+		if cfg.Server.TLSEnabled() {
+			err = e.StartTLS(cfg.Server.ListenAddr, cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		} else {
+			err = e.Start(cfg.Server.ListenAddr)
 		}
+		if err != nil && err != http.ErrServerClosed {
+			e.Logger.Fatal(err)
+		}
+	}()
 
-		// Create schedules for each interval group
-		tickers := make(map[int]*time.Ticker)
-		for interval, endpoints := range intervalGroups {
-			// Create or update schedule
-			schedule := &database.Schedule{
-				Name:      fmt.Sprintf("Schedule for %d second interval", interval),
-				Interval:  interval,
-				CreatedAt: time.Now(),
-				Endpoints: make(pq.Int64Array, len(endpoints)),
-			}
-			for i, endpoint := range endpoints {
-				schedule.Endpoints[i] = int64(endpoint.ID)
-			}
+	<-ctx.Done()
+	log.Println("Shutting down")
 
-			// Create or update schedule in database
-			if err := database.DB.Where("interval = ?", interval).FirstOrCreate(schedule).Error; err != nil {
-				log.Printf("Failed to create/update schedule for interval %d: %v", interval, err)
-				continue
-			}
+	// End open event streams so that the server can shut down
+	broker.Close()
 
-			// Create ticker for this interval
-			ticker := time.NewTicker(time.Duration(interval) * time.Second)
-			tickers[interval] = ticker
-
-			// Start goroutine for this schedule
-			go func(s database.Schedule) {
-				log.Printf("Started monitoring schedule: %s (ID: %d) with interval %d seconds", s.Name, s.ID, s.Interval)
-				for range ticker.C {
-					// Load endpoints for this schedule
-					var currentEndpoints []database.Endpoint
-					if err := database.DB.Where("id = ANY(?) AND (expires_at > ? OR expires_at IS NULL)", s.Endpoints, time.Now()).Find(&currentEndpoints).Error; err != nil {
-						log.Printf("Failed to load endpoints for schedule %d: %v", s.ID, err)
-						continue
-					}
-
-					log.Printf("Schedule %s (ID: %d) checking %d endpoints", s.Name, s.ID, len(currentEndpoints))
-					// Check each endpoint
-					for _, dbEndpoint := range currentEndpoints {
-						endpoint := dbEndpoint.ToModel()
-						go checkEndpoint(&endpoint)
-					}
-				}
-			}(*schedule)
-		}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		e.Logger.Fatal(err)
+	}
+}
 
-		// Keep the function running until an error occurs
-		select {
-		case <-time.After(24 * time.Hour):
-			// Restart every 24 hours to prevent memory leaks
-			log.Println("Restarting health monitoring after 24 hours")
-			return
-		}
+// ipExtractor returns how client addresses are determined. Without trusted
+// proxies the address of the connection is used, as X-Forwarded-For could be
+// sent by the client itself.
+func ipExtractor(server config.ServerConfig) echo.IPExtractor {
+	ranges, err := server.TrustedProxyRanges()
+	if err != nil || len(ranges) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, ipRange := range ranges {
+		options = append(options, echo.TrustIPRange(ipRange))
 	}
+	return echo.ExtractIPFromXFFHeader(options...)
 }
 
-func startExpiryChecker() {
+func startExpiryChecker(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		handlers.CheckExpiredEndpoints()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			handlers.CheckExpiredEndpoints()
+		}
 	}
 }
 
-func checkEndpoint(endpoint *models.Endpoint) {
-	log.Printf("Checking endpoint: %s", endpoint.URL)
+func startEscalationChecker(ctx context.Context) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			notifier.EscalateIncidents()
+		}
 	}
+}
 
-	// Try up to 3 times
-	maxRetries := 3
-	for i := 0; i < maxRetries; i++ {
-		resp, err := client.Get(endpoint.URL)
-		if err != nil {
-			if i < maxRetries-1 {
-				log.Printf("Retry %d/%d for endpoint %s: %v", i+1, maxRetries, endpoint.URL, err)
-				time.Sleep(2 * time.Second)
-				continue
-			}
+func startSessionCleanup(ctx context.Context, loginLockout time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
 
-			endpoint.Status = "error"
-			endpoint.LastChecked = time.Now()
-			if err := database.UpdateEndpointStatus(endpoint.ID, "error"); err != nil {
-				log.Printf("Failed to update endpoint status: %v", err)
-			}
-			log.Printf("Endpoint check failed after %d retries: %s - Status: error", maxRetries, endpoint.URL)
+	for {
+		select {
+		case <-ctx.Done():
 			return
-		}
-		defer resp.Body.Close()
-
-		// Check if status code is not in 2xx range
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			consecutiveFailures[endpoint.ID]++
-			if consecutiveFailures[endpoint.ID] >= 3 {
-				log.Printf("WARNING: Endpoint %s has returned non-2xx status code (%d) for 3 consecutive checks!", endpoint.URL, resp.StatusCode)
+		case <-ticker.C:
+			now := time.Now()
+			if err := database.DeleteExpiredSessions(now); err != nil {
+				log.Printf("Failed to delete expired sessions: %v", err)
+			}
+			// Failed logins are kept for 90 days
+			if err := database.DeleteStaleLoginRecords(now.Add(-loginLockout), now.AddDate(0, 0, -90)); err != nil {
+				log.Printf("Failed to delete old login records: %v", err)
 			}
-		} else {
-			// Reset consecutive failures counter on successful response
-			consecutiveFailures[endpoint.ID] = 0
-		}
-
-		endpoint.Status = "ok"
-		endpoint.LastChecked = time.Now()
-		if err := database.UpdateEndpointStatus(endpoint.ID, "ok"); err != nil {
-			log.Printf("Failed to update endpoint status: %v", err)
 		}
-		log.Printf("Endpoint check successful: %s - Status: ok (HTTP %d)", endpoint.URL, resp.StatusCode)
-		return
 	}
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"api-monitor/config"
+)
+
+func TestIPExtractor(t *testing.T) {
+	tests := []struct {
+		name       string
+		proxies    []string
+		remoteAddr string
+		want       string
+	}{
+		{"no trusted proxies", nil, "10.0.0.5:4321", "10.0.0.5"},
+		{"loopback not trusted by default", nil, "127.0.0.1:4321", "127.0.0.1"},
+		{"trusted range", []string{"10.0.0.0/8"}, "10.0.0.5:4321", "203.0.113.7"},
+		{"trusted address", []string{"10.0.0.5"}, "10.0.0.5:4321", "203.0.113.7"},
+		{"untrusted peer", []string{"10.0.0.0/8"}, "192.168.1.9:4321", "192.168.1.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+			extract := ipExtractor(config.ServerConfig{TrustedProxies: tt.proxies})
+			if got := extract(req); got != tt.want {
+				t.Errorf("client address = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
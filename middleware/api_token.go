@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-monitor/database"
+
+	"github.com/labstack/echo/v4"
+)
+
+// APITokenPrefix starts every personal API token, telling them apart from JWTs
+const APITokenPrefix = "apm_"
+
+// writeScopes maps the API routes that API tokens may change to the scope they require.
+// Other routes, such as token, profile and organization management, only accept reads.
+var writeScopes = map[string]string{
+	"/api/endpoints":           database.ScopeEndpointsWrite,
+	"/api/channels":            database.ScopeChannelsWrite,
+	"/api/incidents":           database.ScopeIncidentsWrite,
+	"/api/maintenance":         database.ScopeMaintenanceWrite,
+	"/api/escalation-policies": database.ScopeEscalationWrite,
+	"/api/status-pages":        database.ScopeStatusPagesWrite,
+}
+
+// sessionOnlyRoutes are the API routes that API tokens cannot use at all
+var sessionOnlyRoutes = []string{
+	"/api/tokens",
+}
+
+// NewAPIToken generates a personal API token
+func NewAPIToken() (string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	return APITokenPrefix + secret, nil
+}
+
+// HashToken returns the stored form of an API or refresh token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateAPIToken authenticates a request with a personal API token and
+// checks that the token's scopes allow it
+func authenticateAPIToken(c echo.Context, next echo.HandlerFunc, tokenString string) error {
+	token, err := database.GetAPITokenByHash(HashToken(tokenString))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Invalid token",
+		})
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{
+			"error": "Token has expired",
+		})
+	}
+
+	if status, message := inactiveUser(token.UserID); status != 0 {
+		return c.JSON(status, map[string]string{
+			"error": message,
+		})
+	}
+
+	if message := scopeError(c, token); message != "" {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": message,
+		})
+	}
+
+	if err := database.TouchAPIToken(token, now, c.RealIP()); err != nil {
+		log.Printf("Failed to record use of API token %d: %v", token.ID, err)
+	}
+
+	c.Set("user_id", token.UserID)
+	c.Set("api_token_id", token.ID)
+	return next(c)
+}
+
+// scopeError returns why the token's scopes do not allow the request, empty if they do
+func scopeError(c echo.Context, token *database.APIToken) string {
+	scope, ok := requiredScope(c)
+	switch {
+	case !ok:
+		return "This route is not available to API tokens"
+	case scope != "" && !token.HasScope(scope):
+		return "Token is missing the " + scope + " scope"
+	}
+	return ""
+}
+
+// requiredScope returns the scope an API token needs for the request, empty if
+// any scope allows it, and false if API tokens cannot make the request at all
+func requiredScope(c echo.Context) (string, bool) {
+	path := c.Path()
+	for _, prefix := range sessionOnlyRoutes {
+		if routeHasPrefix(path, prefix) {
+			return "", false
+		}
+	}
+
+	switch c.Request().Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return "", true
+	}
+
+	for prefix, scope := range writeScopes {
+		if routeHasPrefix(path, prefix) {
+			return scope, true
+		}
+	}
+	return "", false
+}
+
+// routeHasPrefix reports whether a route path is prefix or one of its sub-routes
+func routeHasPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-monitor/database"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestRequiredScope(t *testing.T) {
+	readOnly := &database.APIToken{Scopes: []string{database.ScopeRead}}
+	endpointsWrite := &database.APIToken{Scopes: []string{database.ScopeRead, database.ScopeEndpointsWrite}}
+
+	tests := []struct {
+		name      string
+		method    string
+		route     string
+		scope     string
+		available bool
+		token     *database.APIToken
+		allowed   bool
+	}{
+		{"read", http.MethodGet, "/api/endpoints", "", true, readOnly, true},
+		{"read sub-route", http.MethodGet, "/api/endpoints/:id/checks", "", true, readOnly, true},
+		{"head", http.MethodHead, "/api/incidents/:id", "", true, readOnly, true},
+		{"read of route without write scope", http.MethodGet, "/api/organizations", "", true, readOnly, true},
+		{"write with scope", http.MethodPost, "/api/endpoints", database.ScopeEndpointsWrite, true, endpointsWrite, true},
+		{"write sub-route with scope", http.MethodPut, "/api/endpoints/:id", database.ScopeEndpointsWrite, true, endpointsWrite, true},
+		{"write without scope", http.MethodPost, "/api/endpoints", database.ScopeEndpointsWrite, true, readOnly, false},
+		{"write with other scope", http.MethodDelete, "/api/channels/:id", database.ScopeChannelsWrite, true, endpointsWrite, false},
+		{"incident acknowledgement", http.MethodPost, "/api/incidents/:id/ack", database.ScopeIncidentsWrite, true, readOnly, false},
+		{"status page", http.MethodDelete, "/api/status-pages/:id", database.ScopeStatusPagesWrite, true, readOnly, false},
+		{"tokens read", http.MethodGet, "/api/tokens", "", false, endpointsWrite, false},
+		{"tokens write", http.MethodDelete, "/api/tokens/:id", "", false, endpointsWrite, false},
+		{"unlisted organization write", http.MethodPost, "/api/organizations", "", false, endpointsWrite, false},
+		{"unlisted logout", http.MethodPost, "/api/logout", "", false, endpointsWrite, false},
+		{"unlisted profile write", http.MethodPut, "/api/user", "", false, endpointsWrite, false},
+		{"route sharing a prefix", http.MethodPost, "/api/endpoints-import", "", false, endpointsWrite, false},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := e.NewContext(httptest.NewRequest(tt.method, "/", nil), httptest.NewRecorder())
+			c.SetPath(tt.route)
+
+			scope, available := requiredScope(c)
+			if scope != tt.scope || available != tt.available {
+				t.Errorf("requiredScope() = %q, %v, want %q, %v", scope, available, tt.scope, tt.available)
+			}
+			if message := scopeError(c, tt.token); (message == "") != tt.allowed {
+				t.Errorf("scopeError() for token with scopes %v = %q, want allowed %v", tt.token.Scopes, message, tt.allowed)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"net/http"
 	"strings"
 	"time"
@@ -12,11 +14,12 @@ import (
 )
 
 type jwtCustomClaims struct {
-	UserID uint `json:"user_id"`
+	UserID    uint   `json:"user_id"`
+	SessionID string `json:"sid"` // Refresh token family the access token was issued for
 	jwt.StandardClaims
 }
 
-// JWT middleware
+// JWT middleware authenticates requests with a session JWT or a personal API token
 func JWT(secret []byte) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -34,6 +37,10 @@ func JWT(secret []byte) echo.MiddlewareFunc {
 				})
 			}
 
+			if strings.HasPrefix(tokenString, APITokenPrefix) {
+				return authenticateAPIToken(c, next, tokenString)
+			}
+
 			token, err := jwt.ParseWithClaims(tokenString, &jwtCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
 				return secret, nil
 			})
@@ -51,36 +58,93 @@ func JWT(secret []byte) echo.MiddlewareFunc {
 				})
 			}
 
-			// Check if user exists and is active
-			var user database.User
-			if err := database.DB.First(&user, claims.UserID).Error; err != nil {
+			// Tokens without an ID predate sessions and cannot be revoked
+			if claims.Id == "" || claims.SessionID == "" {
+				return c.JSON(http.StatusUnauthorized, map[string]string{
+					"error": "Session expired, please sign in again",
+				})
+			}
+
+			revoked, err := database.IsTokenRevoked(claims.Id)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": "Failed to check token",
+				})
+			}
+			if revoked {
 				return c.JSON(http.StatusUnauthorized, map[string]string{
-					"error": "User not found",
+					"error": "Token has been revoked",
 				})
 			}
 
-			if !user.IsActive {
-				return c.JSON(http.StatusForbidden, map[string]string{
-					"error": "User account is inactive",
+			if status, message := inactiveUser(claims.UserID); status != 0 {
+				return c.JSON(status, map[string]string{
+					"error": message,
 				})
 			}
 
 			c.Set("user_id", claims.UserID)
+			c.Set("session_id", claims.SessionID)
 			return next(c)
 		}
 	}
 }
 
-// GenerateToken generates a new JWT token for a user
-func GenerateToken(userID uint, secret []byte) (string, error) {
+// inactiveUser returns the status and message of the response for a user that
+// does not exist or is inactive, or 0 if the user may proceed
+func inactiveUser(userID uint) (int, string) {
+	var user database.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return http.StatusUnauthorized, "User not found"
+	}
+
+	if !user.IsActive {
+		return http.StatusForbidden, "User account is inactive"
+	}
+	return 0, ""
+}
+
+// GenerateToken generates a new JWT access token for a session of a user that
+// is valid until expiresAt. It returns the token and its ID (jti).
+func GenerateToken(userID uint, sessionID string, secret []byte, expiresAt time.Time) (string, string, error) {
+	tokenID, err := randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+
 	claims := &jwtCustomClaims{
 		userID,
+		sessionID,
 		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 24).Unix(), // 24 hours
+			Id:        tokenID,
+			ExpiresAt: expiresAt.Unix(),
 			IssuedAt:  time.Now().Unix(),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secret)
+	signed, err := token.SignedString(secret)
+	if err != nil {
+		return "", "", err
+	}
+	return signed, tokenID, nil
+}
+
+// NewRefreshToken generates the secret of a refresh token
+func NewRefreshToken() (string, error) {
+	return randomHex(32)
+}
+
+// NewSessionID generates the ID of a sign-in session
+func NewSessionID() (string, error) {
+	return randomHex(16)
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"api-monitor/database"
+
+	"github.com/labstack/echo/v4"
+)
+
+// OrganizationHeader selects the organization a request acts on
+const OrganizationHeader = "X-Organization-ID"
+
+// Organization middleware resolves the organization of a request from the
+// X-Organization-ID header, defaulting to the user's personal organization,
+// and checks that the user is a member of it. It must run after JWT.
+func Organization() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID := c.Get("user_id").(uint)
+
+			var membership *database.Membership
+			var err error
+			if header := c.Request().Header.Get(OrganizationHeader); header != "" {
+				orgID, parseErr := strconv.ParseUint(header, 10, 64)
+				if parseErr != nil {
+					return c.JSON(http.StatusBadRequest, map[string]string{
+						"error": "Invalid organization ID",
+					})
+				}
+				membership, err = database.GetMembership(uint(orgID), userID)
+			} else {
+				membership, err = database.GetPersonalMembership(userID)
+			}
+
+			if err != nil {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "Not a member of this organization",
+				})
+			}
+
+			c.Set("organization_id", membership.OrganizationID)
+			c.Set("role", membership.Role)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "fmt"
+
+// Assertion types
+const (
+	AssertBodyContains    = "body_contains"
+	AssertBodyNotContains = "body_not_contains"
+	AssertBodyRegex       = "body_regex"
+	AssertJSONPathEquals  = "json_path_equals"
+	AssertJSONPathExists  = "json_path_exists"
+	AssertHeaderEquals    = "header_equals"
+	AssertResponseTimeMax = "response_time_max"
+)
+
+// Assertion is a condition the response of an endpoint must satisfy to be healthy
+type Assertion struct {
+	Type     string `json:"type"`
+	Property string `json:"property,omitempty"` // JSON path or header name
+	Value    string `json:"value,omitempty"`    // Expected value, pattern or limit in milliseconds
+}
+
+// String returns a short description of the assertion
+func (a Assertion) String() string {
+	if a.Property != "" {
+		return fmt.Sprintf("%s %s %q", a.Type, a.Property, a.Value)
+	}
+	return fmt.Sprintf("%s %q", a.Type, a.Value)
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Certificate describes the TLS certificate presented by an endpoint
+type Certificate struct {
+	Subject        string    `json:"subject"`
+	Issuer         string    `json:"issuer"`
+	SANs           []string  `json:"sans"`  // DNS names and IP addresses the certificate is valid for
+	Chain          []string  `json:"chain"` // Subjects of the presented chain, leaf first
+	SerialNumber   string    `json:"serial_number"`
+	NotBefore      time.Time `json:"not_before"`
+	NotAfter       time.Time `json:"not_after"`
+	ChainError     string    `json:"chain_error,omitempty"` // Why the chain failed validation, empty if valid
+	CheckedAt      time.Time `json:"checked_at"`
+	ExpiryNotified bool      `json:"expiry_notified"` // Whether the expiry warning was sent for this certificate
+}
+
+// ExpiresWithin reports whether the certificate expires within the given number of days
+func (c *Certificate) ExpiresWithin(days int) bool {
+	return time.Until(c.NotAfter) < time.Duration(days)*24*time.Hour
+}
+
+// DaysRemaining returns the number of whole days until the certificate expires
+func (c *Certificate) DaysRemaining() int {
+	return int(time.Until(c.NotAfter).Hours() / 24)
+}
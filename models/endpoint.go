@@ -2,12 +2,84 @@ package models
 
 import "time"
 
+// Monitor types
+const (
+	TypeHTTP        = "http"
+	TypeTCP         = "tcp"
+	TypeDNS         = "dns"
+	TypeHeartbeat   = "heartbeat"
+	TypeGRPC        = "grpc"
+	TypeTransaction = "transaction"
+)
+
+// DNS record types
+const (
+	RecordA     = "A"
+	RecordAAAA  = "AAAA"
+	RecordCNAME = "CNAME"
+	RecordMX    = "MX"
+	RecordTXT   = "TXT"
+)
+
 // Endpoint represents an API endpoint to monitor
 type Endpoint struct {
-	ID          int       `json:"id"`
-	URL         string    `json:"url"`
+	ID             int  `json:"id"`
+	OrganizationID uint `json:"organization_id"`
+	UserID         uint `json:"user_id"` // User who created the endpoint
+
+	Type        string    `json:"type"`     // Monitor type, defaults to http
+	URL         string    `json:"url"`      // URL for http, host:port for tcp and grpc, domain name for dns, name for heartbeat and transaction
 	Interval    int       `json:"interval"` // in seconds
 	LastChecked time.Time `json:"last_checked"`
 	Status      string    `json:"status"`
-	ExpiresAt   time.Time `json:"expires_at"` // When the endpoint expires
+	ExpiresAt   time.Time `json:"expires_at"`   // When the endpoint expires
+	LastTimings Timings   `json:"last_timings"` // Latency breakdown of the last check
+
+	// HTTP probe settings
+	Method         string            `json:"method"`          // Defaults to GET
+	Headers        map[string]string `json:"headers"`         // Request headers
+	Body           string            `json:"body"`            // Request body
+	ExpectedStatus []int64           `json:"expected_status"` // Healthy status codes, any 2xx if empty
+
+	Assertions []Assertion `json:"assertions"` // Conditions the response must satisfy
+
+	// TCP probe settings
+	Send   string `json:"send"`   // Data written after connecting
+	Expect string `json:"expect"` // Substring the banner or reply must contain
+
+	// DNS probe settings
+	RecordType     string   `json:"record_type"`     // Defaults to A
+	Resolver       string   `json:"resolver"`        // host:port of the DNS server, the system resolver if empty
+	ExpectedValues []string `json:"expected_values"` // The exact answer set, any answer if empty
+
+	// gRPC probe settings
+	GRPCService string `json:"grpc_service"` // Service to check, the whole server if empty
+	GRPCTLS     bool   `json:"grpc_tls"`     // Connect with TLS
+
+	// Transaction settings
+	Steps []TransactionStep `json:"steps"` // Requests run in order, stopping at the first failure
+
+	// Heartbeat settings
+	HeartbeatToken string     `json:"heartbeat_token"` // Secret path segment of the ping URL, POST /hb/:token
+	GracePeriod    int        `json:"grace_period"`    // Seconds a ping may be late, in addition to the interval
+	LastPingAt     *time.Time `json:"last_ping_at"`
+
+	Tags []string `json:"tags"` // Used to match maintenance windows
+
+	EscalationPolicyID *uint `json:"escalation_policy_id"` // Routes incident alerts through the policy instead of every channel
+
+	// TLS certificate monitoring
+	CertExpiryDays int          `json:"cert_expiry_days"` // Warn this many days before expiry, the checker default if zero
+	Certificate    *Certificate `json:"certificate"`      // Certificate seen by the last https check
+
+	SLATarget float64 `json:"sla_target"` // Uptime percentage to report breaches against
+}
+
+// Timings is the latency breakdown of a check, in milliseconds
+type Timings struct {
+	DNSLookup       int64 `json:"dns_lookup"`
+	TCPConnect      int64 `json:"tcp_connect"`
+	TLSHandshake    int64 `json:"tls_handshake"`
+	TimeToFirstByte int64 `json:"time_to_first_byte"`
+	Total           int64 `json:"total"`
 }
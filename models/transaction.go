@@ -0,0 +1,38 @@
+package models
+
+// Extraction sources
+const (
+	ExtractJSONPath = "json_path"
+	ExtractHeader   = "header"
+	ExtractRegex    = "regex"
+)
+
+// Extraction captures a value from the response of a step into a variable that
+// later steps reference as {{variable}} in their URL, header values, body and assertion values
+type Extraction struct {
+	Variable string `json:"variable"`
+	Source   string `json:"source"`   // json_path, header or regex
+	Property string `json:"property"` // JSON path, header name, or pattern whose first group is captured
+}
+
+// TransactionStep is a single HTTP request of a transaction
+type TransactionStep struct {
+	Name           string            `json:"name"`
+	Method         string            `json:"method"` // Defaults to GET
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers"`
+	Body           string            `json:"body"`
+	ExpectedStatus []int64           `json:"expected_status"` // Healthy status codes, any 2xx if empty
+	Assertions     []Assertion       `json:"assertions"`
+	Extract        []Extraction      `json:"extract"`
+}
+
+// StepResult is the outcome of a single step of a transaction check
+type StepResult struct {
+	Name            string  `json:"name"`
+	Status          int     `json:"status"` // HTTP status code, 0 if no response was received
+	Result          string  `json:"result"`
+	Error           string  `json:"error,omitempty"`
+	FailedAssertion string  `json:"failed_assertion,omitempty"`
+	Timings         Timings `json:"timings"`
+}
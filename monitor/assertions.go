@@ -0,0 +1,122 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"api-monitor/models"
+)
+
+// ValidateAssertion checks that an assertion is well formed
+func ValidateAssertion(a models.Assertion) error {
+	switch a.Type {
+	case models.AssertBodyContains, models.AssertBodyNotContains:
+		if a.Value == "" {
+			return fmt.Errorf("%s assertion requires a value", a.Type)
+		}
+	case models.AssertBodyRegex:
+		if _, err := regexp.Compile(a.Value); err != nil {
+			return fmt.Errorf("invalid regular expression %q: %v", a.Value, err)
+		}
+	case models.AssertJSONPathEquals, models.AssertJSONPathExists:
+		if _, err := parseJSONPath(a.Property); err != nil {
+			return err
+		}
+	case models.AssertHeaderEquals:
+		if a.Property == "" {
+			return fmt.Errorf("%s assertion requires a header name", a.Type)
+		}
+	case models.AssertResponseTimeMax:
+		if ms, err := strconv.ParseInt(a.Value, 10, 64); err != nil || ms <= 0 {
+			return fmt.Errorf("%s assertion requires a positive number of milliseconds", a.Type)
+		}
+	default:
+		return fmt.Errorf("unknown assertion type %q", a.Type)
+	}
+	return nil
+}
+
+// evaluateAssertions checks a response against the assertions of an endpoint
+// and returns the first assertion that does not hold along with the reason
+func evaluateAssertions(assertions []models.Assertion, resp *http.Response, body []byte, elapsed time.Duration) (*models.Assertion, string) {
+	var doc interface{}
+	var docErr error
+	decoded := false
+
+	for i := range assertions {
+		a := &assertions[i]
+		switch a.Type {
+		case models.AssertBodyContains:
+			if !bytes.Contains(body, []byte(a.Value)) {
+				return a, fmt.Sprintf("response body does not contain %q", a.Value)
+			}
+		case models.AssertBodyNotContains:
+			if bytes.Contains(body, []byte(a.Value)) {
+				return a, fmt.Sprintf("response body contains %q", a.Value)
+			}
+		case models.AssertBodyRegex:
+			re, err := regexp.Compile(a.Value)
+			if err != nil {
+				return a, fmt.Sprintf("invalid regular expression %q", a.Value)
+			}
+			if !re.Match(body) {
+				return a, fmt.Sprintf("response body does not match %q", a.Value)
+			}
+		case models.AssertJSONPathEquals, models.AssertJSONPathExists:
+			if !decoded {
+				docErr = json.Unmarshal(body, &doc)
+				decoded = true
+			}
+			if docErr != nil {
+				return a, "response body is not valid JSON"
+			}
+			segments, err := parseJSONPath(a.Property)
+			if err != nil {
+				return a, err.Error()
+			}
+			value, found := lookupJSONPath(doc, segments)
+			if !found {
+				return a, fmt.Sprintf("%s not found in response body", a.Property)
+			}
+			if a.Type == models.AssertJSONPathEquals {
+				if actual := jsonValueString(value); actual != a.Value {
+					return a, fmt.Sprintf("%s is %q, expected %q", a.Property, actual, a.Value)
+				}
+			}
+		case models.AssertHeaderEquals:
+			if actual := resp.Header.Get(a.Property); actual != a.Value {
+				return a, fmt.Sprintf("header %s is %q, expected %q", a.Property, actual, a.Value)
+			}
+		case models.AssertResponseTimeMax:
+			limit, err := strconv.ParseInt(a.Value, 10, 64)
+			if err != nil {
+				return a, fmt.Sprintf("invalid response time limit %q", a.Value)
+			}
+			if elapsed.Milliseconds() > limit {
+				return a, fmt.Sprintf("response time %dms exceeds %dms", elapsed.Milliseconds(), limit)
+			}
+		default:
+			return a, fmt.Sprintf("unknown assertion type %q", a.Type)
+		}
+	}
+
+	return nil, ""
+}
+
+// jsonValueString formats a decoded JSON value for comparison.
+// Strings are compared as is, other values by their JSON encoding.
+func jsonValueString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
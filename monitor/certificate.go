@@ -0,0 +1,177 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/models"
+	"api-monitor/notifier"
+)
+
+// StatusWarning is the endpoint status while its checks pass but its certificate is about to expire
+const StatusWarning = "warning"
+
+// certificateCapture records the certificate presented during a TLS handshake.
+// The handshake runs on the transport's dialing goroutine, hence the lock.
+type certificateCapture struct {
+	mu   sync.Mutex
+	host string // Host being dialled, which the certificate must be valid for
+	cert *models.Certificate
+}
+
+// certificateRoots are the CAs certificate chains are verified against, nil for the system roots
+var certificateRoots *x509.CertPool
+
+// setHost changes the host the next connection is made to, as when following a redirect
+func (c *certificateCapture) setHost(host string) {
+	c.mu.Lock()
+	c.host = host
+	c.mu.Unlock()
+}
+
+// tlsConfig returns a client TLS config that verifies the peer chain like the
+// default config does, but records the certificate whether or not it is valid
+func (c *certificateCapture) tlsConfig() *tls.Config {
+	return &tls.Config{
+		// Verification is done in VerifyConnection so that invalid chains are captured too
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			cert, err := verifyCertificate(cs, c.host, certificateRoots)
+			c.cert = cert
+			return err
+		},
+	}
+}
+
+// take returns the captured certificate and clears it for the next attempt
+func (c *certificateCapture) take() *models.Certificate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cert := c.cert
+	c.cert = nil
+	return cert
+}
+
+// verifyCertificate validates the peer chain against roots and the dialled
+// host, and describes the leaf certificate. The host is checked rather than
+// the server name, which is empty when connecting to an IP address.
+func verifyCertificate(cs tls.ConnectionState, host string, roots *x509.CertPool) (*models.Certificate, error) {
+	if len(cs.PeerCertificates) == 0 {
+		return nil, nil
+	}
+
+	leaf := cs.PeerCertificates[0]
+	cert := &models.Certificate{
+		Subject:      leaf.Subject.String(),
+		Issuer:       leaf.Issuer.String(),
+		SANs:         leaf.DNSNames,
+		SerialNumber: leaf.SerialNumber.String(),
+		NotBefore:    leaf.NotBefore,
+		NotAfter:     leaf.NotAfter,
+		CheckedAt:    time.Now(),
+	}
+	for _, ip := range leaf.IPAddresses {
+		cert.SANs = append(cert.SANs, ip.String())
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range cs.PeerCertificates {
+		cert.Chain = append(cert.Chain, c.Subject.String())
+		if c != leaf {
+			intermediates.AddCert(c)
+		}
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		cert.ChainError = err.Error()
+	}
+	return cert, err
+}
+
+// requestHost returns the host a URL is requested from
+func requestHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// dialHost returns the host of a host:port address
+func dialHost(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
+// certExpiryDays returns how many days before certificate expiry an endpoint warns
+func certExpiryDays(endpoint *models.Endpoint) int {
+	if endpoint.CertExpiryDays > 0 {
+		return endpoint.CertExpiryDays
+	}
+	return settings.CertExpiryDays
+}
+
+// certificateExpiring reports whether a check passed with a certificate inside the warning window
+func certificateExpiring(endpoint *models.Endpoint, check *database.HealthCheck) bool {
+	return check.Result == ResultOK && check.Certificate != nil && check.Certificate.ExpiresWithin(certExpiryDays(endpoint))
+}
+
+// trackCertificate stores the certificate seen by a check and sends a single
+// expiry warning per certificate once it enters the warning window
+func trackCertificate(endpoint *models.Endpoint, check *database.HealthCheck) {
+	cert := check.Certificate
+	if cert == nil {
+		return
+	}
+
+	state := stateFor(endpoint.ID)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	// Pick up the warning state left by a previous run
+	if !state.certLoaded {
+		previous, err := database.GetEndpointCertificate(endpoint.ID)
+		if err != nil {
+			log.Printf("Failed to load certificate of endpoint %d: %v", endpoint.ID, err)
+			return
+		}
+		state.cert = previous
+		state.certLoaded = true
+	}
+
+	previous := state.cert
+	if previous != nil && previous.SerialNumber == cert.SerialNumber && previous.NotAfter.Equal(cert.NotAfter) {
+		cert.ExpiryNotified = previous.ExpiryNotified
+	}
+
+	if certificateExpiring(endpoint, check) && !cert.ExpiryNotified {
+		log.Printf("WARNING: Certificate of endpoint %s expires on %s", endpoint.URL, cert.NotAfter.Format(time.RFC1123))
+		cert.ExpiryNotified = true
+		event := newEvent(notifier.EventCertExpiring, endpoint, check)
+		event.CertExpiresAt = &cert.NotAfter
+		go dispatch(endpoint.OrganizationID, event)
+	}
+
+	if err := database.UpdateEndpointCertificate(endpoint.ID, cert); err != nil {
+		log.Printf("Failed to store certificate of endpoint %d: %v", endpoint.ID, err)
+		return
+	}
+	state.cert = cert
+	endpoint.Certificate = cert
+}
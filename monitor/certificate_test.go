@@ -0,0 +1,160 @@
+package monitor
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/models"
+)
+
+// withSettings replaces the probe settings for the duration of a test
+func withSettings(t *testing.T, change func()) {
+	previous := settings
+	change()
+	t.Cleanup(func() { settings = previous })
+}
+
+func TestProbeHTTPCapturesCertificate(t *testing.T) {
+	withSettings(t, func() { settings.Retries = 1 })
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	leaf := server.Certificate()
+
+	check := probeHTTP(context.Background(), &models.Endpoint{URL: server.URL})
+
+	// The test certificate is not signed by a trusted root, but is captured anyway
+	if check.Result != ResultError {
+		t.Errorf("Result = %q, want %q", check.Result, ResultError)
+	}
+	cert := check.Certificate
+	if cert == nil {
+		t.Fatal("Certificate = nil, want the server certificate")
+	}
+	if !cert.NotAfter.Equal(leaf.NotAfter) {
+		t.Errorf("NotAfter = %s, want %s", cert.NotAfter, leaf.NotAfter)
+	}
+	if cert.SerialNumber != leaf.SerialNumber.String() {
+		t.Errorf("SerialNumber = %s, want %s", cert.SerialNumber, leaf.SerialNumber)
+	}
+	if cert.Issuer != leaf.Issuer.String() {
+		t.Errorf("Issuer = %q, want %q", cert.Issuer, leaf.Issuer)
+	}
+	if !containsString(cert.SANs, "example.com") || !containsString(cert.SANs, "127.0.0.1") {
+		t.Errorf("SANs = %v, want example.com and 127.0.0.1", cert.SANs)
+	}
+	if cert.ChainError == "" {
+		t.Error("ChainError is empty for an untrusted certificate")
+	}
+}
+
+func TestProbeHTTPVerifiesHost(t *testing.T) {
+	withSettings(t, func() { settings.Retries = 1 })
+
+	// The test certificate is valid for 127.0.0.1, ::1 and example.com
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target := r.URL.Query().Get("to"); target != "" {
+			http.Redirect(w, r, target, http.StatusFound)
+		}
+	})
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	// A server on another loopback address presenting the same certificate
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("cannot listen on 127.0.0.2: %v", err)
+	}
+	other := httptest.NewUnstartedServer(handler)
+	other.Listener.Close()
+	other.Listener = listener
+	other.StartTLS()
+	defer other.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	previous := certificateRoots
+	certificateRoots = roots
+	t.Cleanup(func() { certificateRoots = previous })
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	tests := []struct {
+		name      string
+		url       string
+		hostError string // Host the certificate is reported invalid for, empty if valid
+	}{
+		{"ip in certificate", server.URL, ""},
+		{"ip not in certificate", other.URL, "127.0.0.2"},
+		{"name not in certificate", "https://localhost:" + port, "localhost"},
+		{"redirect to ip not in certificate", server.URL + "/?to=" + other.URL, "127.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := probeHTTP(context.Background(), &models.Endpoint{URL: tt.url})
+			if check.Certificate == nil {
+				t.Fatalf("Certificate = nil, want the server certificate (error %q)", check.Error)
+			}
+
+			if tt.hostError == "" {
+				if check.Result != ResultOK || check.Certificate.ChainError != "" {
+					t.Errorf("Result = %q, ChainError = %q, want a valid certificate", check.Result, check.Certificate.ChainError)
+				}
+				return
+			}
+			if check.Result != ResultError {
+				t.Errorf("Result = %q, want %q", check.Result, ResultError)
+			}
+			if !strings.Contains(check.Certificate.ChainError, "not "+tt.hostError) {
+				t.Errorf("ChainError = %q, want the certificate reported invalid for %s", check.Certificate.ChainError, tt.hostError)
+			}
+		})
+	}
+}
+
+func TestCertificateExpiring(t *testing.T) {
+	withSettings(t, func() { settings.CertExpiryDays = 14 })
+
+	expiresIn := func(days int) *models.Certificate {
+		return &models.Certificate{NotAfter: time.Now().Add(time.Duration(days) * 24 * time.Hour)}
+	}
+
+	tests := []struct {
+		name     string
+		endpoint models.Endpoint
+		check    database.HealthCheck
+		want     bool
+	}{
+		{"inside default window", models.Endpoint{}, database.HealthCheck{Result: ResultOK, Certificate: expiresIn(5)}, true},
+		{"outside default window", models.Endpoint{}, database.HealthCheck{Result: ResultOK, Certificate: expiresIn(30)}, false},
+		{"inside endpoint window", models.Endpoint{CertExpiryDays: 45}, database.HealthCheck{Result: ResultOK, Certificate: expiresIn(30)}, true},
+		{"expired", models.Endpoint{}, database.HealthCheck{Result: ResultOK, Certificate: expiresIn(-1)}, true},
+		{"failed check", models.Endpoint{}, database.HealthCheck{Result: ResultError, Certificate: expiresIn(5)}, false},
+		{"no certificate", models.Endpoint{}, database.HealthCheck{Result: ResultOK}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := certificateExpiring(&tt.endpoint, &tt.check); got != tt.want {
+				t.Errorf("certificateExpiring() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/models"
+)
+
+// Check results
+const (
+	ResultOK      = "ok"
+	ResultError   = "error"
+	ResultSkipped = "skipped"
+
+	// ResultMaintenance marks checks that ran during a maintenance window. They
+	// do not count towards failures, incidents or uptime.
+	ResultMaintenance = "maintenance"
+)
+
+// StatusMaintenance is the endpoint status while it is in a maintenance window
+const StatusMaintenance = "maintenance"
+
+// CheckEndpoint probes an endpoint, updates its status and records the result.
+// Nothing is recorded if ctx is cancelled during the check or the probe had nothing to report.
+// Endpoints in a maintenance window that skips checks are not probed.
+func CheckEndpoint(ctx context.Context, endpoint *models.Endpoint) {
+	if window := ActiveMaintenance(endpoint, time.Now()); window != nil && window.Mode == database.MaintenanceSkip {
+		log.Printf("Skipping check of endpoint %s: in maintenance window %q", endpoint.URL, window.Name)
+		if err := database.SetEndpointStatus(endpoint.ID, StatusMaintenance); err != nil {
+			log.Printf("Failed to update endpoint status: %v", err)
+		}
+		publishStatus(endpoint, endpoint.Status, StatusMaintenance)
+		return
+	}
+
+	log.Printf("Checking endpoint: %s", endpoint.URL)
+
+	check := probe(ctx, endpoint)
+	if check == nil || ctx.Err() != nil {
+		return
+	}
+	processResult(endpoint, check)
+
+	switch check.Result {
+	case ResultMaintenance:
+		log.Printf("Endpoint check during maintenance: %s", endpoint.URL)
+	case ResultOK:
+		log.Printf("Endpoint check successful: %s - Status: ok", endpoint.URL)
+	default:
+		log.Printf("Endpoint check failed: %s - Status: error (%s)", endpoint.URL, check.Error)
+	}
+}
+
+// processResult records a check result and updates the flapping, certificate,
+// failure and incident state of the endpoint. Checks during a maintenance window
+// are recorded as maintenance and leave that state untouched.
+func processResult(endpoint *models.Endpoint, check *database.HealthCheck) {
+	if window := ActiveMaintenance(endpoint, time.Now()); window != nil {
+		check.Result = ResultMaintenance
+		recordResult(endpoint, check, StatusMaintenance)
+		return
+	}
+
+	healthy := check.Result == ResultOK
+	flapping, change := trackFlapping(endpoint, healthy)
+
+	status := check.Result
+	if flapping {
+		status = StatusFlapping
+	} else if certificateExpiring(endpoint, check) {
+		status = StatusWarning
+	}
+	recordResult(endpoint, check, status)
+
+	trackCertificate(endpoint, check)
+	trackFailures(endpoint, check, healthy)
+	notifyFlapping(endpoint, check, change)
+}
+
+// recordResult updates the endpoint status and persists the health check
+func recordResult(endpoint *models.Endpoint, check *database.HealthCheck, status string) {
+	check.EndpointID = endpoint.ID
+	check.CheckedAt = time.Now()
+
+	previous := endpoint.Status
+	endpoint.Status = status
+	endpoint.LastChecked = check.CheckedAt
+	endpoint.LastTimings = check.Timings()
+	if err := database.UpdateEndpointStatus(endpoint.ID, endpoint.Status, endpoint.LastTimings); err != nil {
+		log.Printf("Failed to update endpoint status: %v", err)
+	}
+
+	if err := database.CreateHealthCheck(check); err != nil {
+		log.Printf("Failed to record health check for endpoint %d: %v", endpoint.ID, err)
+	}
+
+	publishCheck(endpoint, check)
+	publishStatus(endpoint, previous, status)
+}
+
+// RecordSkipped records a due check that did not run. It does not change the
+// endpoint status and does not count towards failures.
+func RecordSkipped(endpoint *models.Endpoint, reason string) {
+	log.Printf("Skipped check of endpoint %s: %s", endpoint.URL, reason)
+
+	check := &database.HealthCheck{
+		EndpointID: endpoint.ID,
+		Result:     ResultSkipped,
+		Error:      reason,
+		CheckedAt:  time.Now(),
+	}
+	if err := database.CreateHealthCheck(check); err != nil {
+		log.Printf("Failed to record skipped check for endpoint %d: %v", endpoint.ID, err)
+	}
+}
@@ -0,0 +1,158 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/models"
+)
+
+// probeDNS resolves the configured record of an endpoint and compares the
+// answer set with the expected values. Temporary resolver failures are retried.
+func probeDNS(ctx context.Context, endpoint *models.Endpoint) *database.HealthCheck {
+	return withRetries(ctx, endpoint, func() (*database.HealthCheck, error) {
+		return doDNSLookup(ctx, endpoint)
+	})
+}
+
+// doDNSLookup performs a single lookup. The returned error is set only when
+// the resolver failed temporarily and the lookup may be retried.
+func doDNSLookup(ctx context.Context, endpoint *models.Endpoint) (*database.HealthCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, settings.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	answers, err := lookupRecords(ctx, newResolver(endpoint.Resolver), endpoint.RecordType, endpoint.URL)
+	elapsed := time.Since(start).Milliseconds()
+
+	check := &database.HealthCheck{
+		Result:   ResultOK,
+		Response: truncateBody([]byte(strings.Join(answers, "\n"))),
+	}
+	check.SetTimings(models.Timings{DNSLookup: elapsed, Total: elapsed})
+
+	if err != nil {
+		check.Result = ResultError
+		check.Error = err.Error()
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout) {
+			return check, err
+		}
+		return check, nil
+	}
+
+	if len(answers) == 0 {
+		check.Result = ResultError
+		check.Error = "No records found"
+	} else if len(endpoint.ExpectedValues) > 0 && !sameAnswers(endpoint.RecordType, answers, endpoint.ExpectedValues) {
+		check.Result = ResultError
+		check.Error = fmt.Sprintf("Unexpected answer [%s], expected [%s]",
+			strings.Join(answers, ", "), strings.Join(endpoint.ExpectedValues, ", "))
+	}
+	return check, nil
+}
+
+// newResolver returns a resolver that queries the given host:port, or the system resolver if empty
+func newResolver(address string) *net.Resolver {
+	if address == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// lookupRecords resolves the records of the given type for a name
+func lookupRecords(ctx context.Context, resolver *net.Resolver, recordType, name string) ([]string, error) {
+	var answers []string
+	switch recordType {
+	case models.RecordA, models.RecordAAAA, "":
+		network := "ip4"
+		if recordType == models.RecordAAAA {
+			network = "ip6"
+		}
+		ips, err := resolver.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	case models.RecordCNAME:
+		cname, err := resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, cname)
+	case models.RecordMX:
+		records, err := resolver.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range records {
+			answers = append(answers, mx.Host)
+		}
+	case models.RecordTXT:
+		records, err := resolver.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = records
+	default:
+		return nil, fmt.Errorf("unsupported record type %q", recordType)
+	}
+	return answers, nil
+}
+
+// sameAnswers reports whether two answer sets of a record type contain the same values, ignoring order
+func sameAnswers(recordType string, answers, expected []string) bool {
+	normalize := func(values []string) []string {
+		set := make(map[string]bool, len(values))
+		for _, v := range values {
+			set[normalizeAnswer(recordType, v)] = true
+		}
+		out := make([]string, 0, len(set))
+		for v := range set {
+			out = append(out, v)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	a, b := normalize(answers), normalize(expected)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeAnswer returns the canonical form of an answer: IP addresses are
+// formatted consistently and names lose letter case and the trailing dot
+func normalizeAnswer(recordType, value string) string {
+	switch recordType {
+	case models.RecordTXT:
+		return value
+	case models.RecordCNAME, models.RecordMX:
+		return strings.ToLower(strings.TrimSuffix(value, "."))
+	default:
+		if ip := net.ParseIP(value); ip != nil {
+			return ip.String()
+		}
+		return value
+	}
+}
@@ -0,0 +1,56 @@
+package monitor
+
+import (
+	"api-monitor/broker"
+	"api-monitor/database"
+	"api-monitor/models"
+)
+
+// publishCheck publishes a recorded check to the streams of the endpoint's organization
+func publishCheck(endpoint *models.Endpoint, check *database.HealthCheck) {
+	broker.Publish(broker.Event{
+		Type:           broker.EventCheck,
+		OrganizationID: endpoint.OrganizationID,
+		EndpointID:     endpoint.ID,
+		Time:           check.CheckedAt,
+		Data:           check,
+	})
+}
+
+// publishStatus publishes the status of an endpoint if it differs from the last
+// one published. stored is the status the endpoint had before this change, used
+// when nothing was published since startup.
+func publishStatus(endpoint *models.Endpoint, stored, status string) {
+	state := stateFor(endpoint.ID)
+	state.mu.Lock()
+	previous := state.status
+	if previous == "" {
+		previous = stored
+	}
+	state.status = status
+	state.mu.Unlock()
+
+	if previous == status {
+		return
+	}
+	broker.Publish(broker.Event{
+		Type:           broker.EventStatus,
+		OrganizationID: endpoint.OrganizationID,
+		EndpointID:     endpoint.ID,
+		Data:           broker.StatusChange{From: previous, To: status},
+	})
+}
+
+// publishIncident publishes a change to an incident of an endpoint
+func publishIncident(endpoint *models.Endpoint, incidentID uint, status, message string) {
+	broker.Publish(broker.Event{
+		Type:           broker.EventIncident,
+		OrganizationID: endpoint.OrganizationID,
+		EndpointID:     endpoint.ID,
+		Data: broker.IncidentChange{
+			IncidentID: incidentID,
+			Status:     status,
+			Message:    message,
+		},
+	})
+}
@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"log"
+
+	"api-monitor/database"
+	"api-monitor/models"
+	"api-monitor/notifier"
+)
+
+// StatusFlapping is the endpoint status while its checks keep alternating between passing and failing
+const StatusFlapping = "flapping"
+
+// flapChange reports how the flapping state of an endpoint changed with a check
+type flapChange int
+
+const (
+	flapUnchanged flapChange = iota
+	flapStarted
+	flapStopped
+)
+
+// trackFlapping adds a check result to the recent history of an endpoint and
+// decides whether it is flapping. Flapping starts once the percentage of state
+// changes within the window reaches the start threshold and stops once it falls
+// to the stop threshold. It returns whether the endpoint is flapping now.
+func trackFlapping(endpoint *models.Endpoint, healthy bool) (bool, flapChange) {
+	window := settings.FlapWindow
+	if window == 0 {
+		return false, flapUnchanged
+	}
+
+	state := stateFor(endpoint.ID)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	// Rebuild the history from the stored checks after a restart
+	if !state.historyLoaded {
+		checks, err := database.GetRecentHealthChecks(endpoint.ID, window)
+		if err != nil {
+			log.Printf("Failed to load check history of endpoint %d: %v", endpoint.ID, err)
+		}
+		for i := len(checks) - 1; i >= 0; i-- {
+			if r := checks[i].Result; r != ResultSkipped && r != ResultMaintenance {
+				state.history = append(state.history, checks[i].Result == ResultOK)
+			}
+		}
+		state.flapping = endpoint.Status == StatusFlapping
+		state.historyLoaded = true
+	}
+
+	state.history = append(state.history, healthy)
+	if len(state.history) > window {
+		state.history = state.history[len(state.history)-window:]
+	}
+
+	// Wait for a full window before deciding
+	if len(state.history) < window {
+		return state.flapping, flapUnchanged
+	}
+
+	changes := 0
+	for i := 1; i < len(state.history); i++ {
+		if state.history[i] != state.history[i-1] {
+			changes++
+		}
+	}
+	percent := float64(changes) / float64(len(state.history)-1) * 100
+
+	switch {
+	case !state.flapping && percent >= settings.FlapStartThreshold:
+		state.flapping = true
+		log.Printf("WARNING: Endpoint %s is flapping (%.0f%% state changes)", endpoint.URL, percent)
+		return true, flapStarted
+	case state.flapping && percent <= settings.FlapStopThreshold:
+		state.flapping = false
+		log.Printf("Endpoint %s stopped flapping (%.0f%% state changes)", endpoint.URL, percent)
+		return false, flapStopped
+	}
+	return state.flapping, flapUnchanged
+}
+
+// notifyFlapping sends the single event that replaces the alerts suppressed while
+// an endpoint flaps. Once it stops, the alerts still relevant are sent too.
+func notifyFlapping(endpoint *models.Endpoint, check *database.HealthCheck, change flapChange) {
+	switch change {
+	case flapStarted:
+		go dispatch(endpoint.OrganizationID, newEvent(notifier.EventFlappingStarted, endpoint, check))
+	case flapStopped:
+		go dispatch(endpoint.OrganizationID, newEvent(notifier.EventFlappingStopped, endpoint, check))
+		releaseSuppressedAlerts(endpoint, check)
+	}
+}
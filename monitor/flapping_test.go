@@ -0,0 +1,218 @@
+package monitor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/models"
+	"api-monitor/notifier"
+)
+
+// withState sets the tracked state of an endpoint for the duration of a test
+func withState(t *testing.T, endpointID int, state *endpointState) {
+	statesMu.Lock()
+	states[endpointID] = state
+	statesMu.Unlock()
+	t.Cleanup(func() { ForgetEndpoint(endpointID) })
+}
+
+func TestTrackFlapping(t *testing.T) {
+	withSettings(t, func() {
+		settings.FlapWindow = 5
+		settings.FlapStartThreshold = 50
+		settings.FlapStopThreshold = 25
+	})
+
+	const (
+		u = flapUnchanged
+		s = flapStarted
+		p = flapStopped
+	)
+
+	tests := []struct {
+		name    string
+		results []bool
+		changes []flapChange // For each result
+	}{
+		{
+			name:    "steady",
+			results: []bool{true, true, true, true, true, true},
+			changes: []flapChange{u, u, u, u, u, u},
+		},
+		{
+			name:    "waits for a full window",
+			results: []bool{true, false, true, false},
+			changes: []flapChange{u, u, u, u},
+		},
+		{
+			name:    "below start threshold",
+			results: []bool{true, false, false, false, false},
+			changes: []flapChange{u, u, u, u, u},
+		},
+		{
+			name:    "starts at start threshold",
+			results: []bool{true, false, true, true, true},
+			changes: []flapChange{u, u, u, u, s},
+		},
+		{
+			name:    "keeps flapping above stop threshold",
+			results: []bool{true, false, true, false, true, true, true},
+			changes: []flapChange{u, u, u, u, s, u, u},
+		},
+		{
+			name:    "stops at stop threshold",
+			results: []bool{true, false, true, false, true, true, true, true},
+			changes: []flapChange{u, u, u, u, s, u, u, p},
+		},
+		{
+			name:    "starts again",
+			results: []bool{true, false, true, false, true, true, true, true, false, true},
+			changes: []flapChange{u, u, u, u, s, u, u, p, u, s},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := &models.Endpoint{ID: 1000 + i, URL: "https://example.com"}
+			withState(t, endpoint.ID, &endpointState{historyLoaded: true})
+
+			want := false
+			for j, healthy := range tt.results {
+				switch tt.changes[j] {
+				case s:
+					want = true
+				case p:
+					want = false
+				}
+
+				flapping, change := trackFlapping(endpoint, healthy)
+				if change != tt.changes[j] {
+					t.Errorf("check %d: change = %d, want %d", j+1, change, tt.changes[j])
+				}
+				if flapping != want {
+					t.Errorf("check %d: flapping = %v, want %v", j+1, flapping, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTrackFlappingDisabled(t *testing.T) {
+	withSettings(t, func() { settings.FlapWindow = 0 })
+
+	endpoint := &models.Endpoint{ID: 1100}
+	for i := 0; i < 10; i++ {
+		if flapping, change := trackFlapping(endpoint, i%2 == 0); flapping || change != flapUnchanged {
+			t.Fatalf("trackFlapping() = %v, %d with flap detection disabled", flapping, change)
+		}
+	}
+}
+
+func TestReleaseSuppressedAlerts(t *testing.T) {
+	sent := make(chan string, 10)
+	previousDispatch, previousDispatchIncident, previousEscalate := dispatch, dispatchIncident, escalate
+	dispatch = func(orgID uint, event notifier.Event) { sent <- fmt.Sprintf("dispatch %d %s", orgID, event.Type) }
+	dispatchIncident = func(incidentID uint, event notifier.Event) {
+		sent <- fmt.Sprintf("incident %d %s", incidentID, event.Type)
+	}
+	escalate = func(incidentID uint) { sent <- fmt.Sprintf("escalate %d", incidentID) }
+	t.Cleanup(func() {
+		dispatch, dispatchIncident, escalate = previousDispatch, previousDispatchIncident, previousEscalate
+	})
+
+	policyID := uint(3)
+	recovered := notifier.Event{Type: notifier.EventRecovered}
+
+	tests := []struct {
+		name     string
+		endpoint models.Endpoint
+		state    *endpointState
+		want     []string
+	}{
+		{
+			name:     "held back down alert",
+			endpoint: models.Endpoint{OrganizationID: 2},
+			state:    &endpointState{incidentID: 7, downPending: true},
+			want:     []string{"dispatch 2 DOWN"},
+		},
+		{
+			name:     "held back down alert with escalation policy",
+			endpoint: models.Endpoint{OrganizationID: 2, EscalationPolicyID: &policyID},
+			state:    &endpointState{incidentID: 7, downPending: true},
+			want:     []string{"escalate 7"},
+		},
+		{
+			name:     "held back recovery alert",
+			endpoint: models.Endpoint{OrganizationID: 2},
+			state:    &endpointState{recoveryPending: &suppressedRecovery{incidentID: 6, event: recovered}},
+			want:     []string{"incident 6 RECOVERED"},
+		},
+		{
+			name:     "recovery of an earlier incident and down alert of the open one",
+			endpoint: models.Endpoint{OrganizationID: 2},
+			state:    &endpointState{incidentID: 7, downPending: true, recoveryPending: &suppressedRecovery{incidentID: 6, event: recovered}},
+			want:     []string{"incident 6 RECOVERED", "dispatch 2 DOWN"},
+		},
+		{
+			name:     "open incident already alerted",
+			endpoint: models.Endpoint{OrganizationID: 2},
+			state:    &endpointState{incidentID: 7},
+		},
+		{
+			name:     "nothing held back",
+			endpoint: models.Endpoint{OrganizationID: 2},
+			state:    &endpointState{},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.endpoint.ID = 1200 + i
+			state := tt.state
+			withState(t, tt.endpoint.ID, state)
+
+			releaseSuppressedAlerts(&tt.endpoint, &database.HealthCheck{})
+
+			got := make(map[string]bool)
+			for range tt.want {
+				got[waitForAlert(t, sent)] = true
+			}
+			for _, want := range tt.want {
+				if !got[want] {
+					t.Errorf("alerts %v, want %v", got, tt.want)
+				}
+			}
+			select {
+			case alert := <-sent:
+				t.Errorf("unexpected alert %q", alert)
+			case <-time.After(20 * time.Millisecond):
+			}
+
+			if state.downPending || state.recoveryPending != nil {
+				t.Error("alerts are still held back after being released")
+			}
+
+			// Released alerts are only sent once
+			releaseSuppressedAlerts(&tt.endpoint, &database.HealthCheck{})
+			select {
+			case alert := <-sent:
+				t.Errorf("alert %q sent again", alert)
+			case <-time.After(20 * time.Millisecond):
+			}
+		})
+	}
+}
+
+// waitForAlert receives a sent alert or fails the test after a second
+func waitForAlert(t *testing.T, sent <-chan string) string {
+	t.Helper()
+	select {
+	case alert := <-sent:
+		return alert
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an alert")
+		return ""
+	}
+}
@@ -0,0 +1,83 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// probeGRPC calls the standard gRPC health service of an endpoint. Calls that
+// fail because the server cannot be reached are retried.
+func probeGRPC(ctx context.Context, endpoint *models.Endpoint) *database.HealthCheck {
+	capture := &certificateCapture{host: dialHost(endpoint.URL)}
+	creds := insecure.NewCredentials()
+	if endpoint.GRPCTLS {
+		creds = credentials.NewTLS(capture.tlsConfig())
+	}
+
+	conn, err := grpc.NewClient(endpoint.URL, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return &database.HealthCheck{
+			Result: ResultError,
+			Error:  "Invalid address: " + err.Error(),
+		}
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	return withRetries(ctx, endpoint, func() (*database.HealthCheck, error) {
+		check, err := doGRPCHealthCheck(ctx, client, endpoint)
+		check.Certificate = capture.take()
+		return check, err
+	})
+}
+
+// doGRPCHealthCheck performs a single health call. The returned error is set
+// only when the server could not be reached and the call may be retried.
+func doGRPCHealthCheck(ctx context.Context, client healthpb.HealthClient, endpoint *models.Endpoint) (*database.HealthCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, settings.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: endpoint.GRPCService})
+	elapsed := time.Since(start).Milliseconds()
+
+	check := &database.HealthCheck{}
+	check.SetTimings(models.Timings{Total: elapsed})
+
+	if err != nil {
+		check.Result = ResultError
+		st := status.Convert(err)
+		switch st.Code() {
+		case codes.NotFound:
+			check.Error = fmt.Sprintf("Service %q is unknown to the server", endpoint.GRPCService)
+		case codes.Unimplemented:
+			check.Error = "Server does not implement the gRPC health service"
+		case codes.Unavailable, codes.DeadlineExceeded:
+			check.Error = st.Message()
+			return check, err
+		default:
+			check.Error = fmt.Sprintf("%s: %s", st.Code(), st.Message())
+		}
+		return check, nil
+	}
+
+	check.Response = resp.Status.String()
+	if resp.Status == healthpb.HealthCheckResponse_SERVING {
+		check.Result = ResultOK
+	} else {
+		check.Result = ResultError
+		check.Error = "Health status " + resp.Status.String()
+	}
+	return check, nil
+}
@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"api-monitor/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startGRPCServer serves on a local port, with the health service if health is set
+func startGRPCServer(t *testing.T, health *health.Server) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	if health != nil {
+		healthpb.RegisterHealthServer(server, health)
+	}
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func TestProbeGRPC(t *testing.T) {
+	withSettings(t, func() { settings.Retries = 1 })
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("serving", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("not-serving", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthServer.SetServingStatus("unknown", healthpb.HealthCheckResponse_UNKNOWN)
+	addr := startGRPCServer(t, healthServer)
+	withoutHealth := startGRPCServer(t, nil)
+
+	tests := []struct {
+		name     string
+		addr     string
+		service  string
+		result   string
+		response string
+		error    string
+	}{
+		{"whole server", addr, "", ResultOK, "SERVING", ""},
+		{"serving", addr, "serving", ResultOK, "SERVING", ""},
+		{"not serving", addr, "not-serving", ResultError, "NOT_SERVING", "Health status NOT_SERVING"},
+		{"unknown", addr, "unknown", ResultError, "UNKNOWN", "Health status UNKNOWN"},
+		{"unregistered service", addr, "missing", ResultError, "", `Service "missing" is unknown to the server`},
+		{"no health service", withoutHealth, "", ResultError, "", "Server does not implement the gRPC health service"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := probeGRPC(context.Background(), &models.Endpoint{
+				Type:        models.TypeGRPC,
+				URL:         tt.addr,
+				GRPCService: tt.service,
+			})
+			if check.Result != tt.result {
+				t.Errorf("Result = %q, want %q (error %q)", check.Result, tt.result, check.Error)
+			}
+			if check.Response != tt.response {
+				t.Errorf("Response = %q, want %q", check.Response, tt.response)
+			}
+			if check.Error != tt.error {
+				t.Errorf("Error = %q, want %q", check.Error, tt.error)
+			}
+		})
+	}
+}
+
+func TestProbeGRPCUnreachable(t *testing.T) {
+	withSettings(t, func() { settings.Retries = 1 })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	check := probeGRPC(context.Background(), &models.Endpoint{Type: models.TypeGRPC, URL: addr})
+	if check.Result != ResultError || check.Error == "" {
+		t.Errorf("check = %+v, want an error result", check)
+	}
+}
@@ -0,0 +1,56 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/models"
+)
+
+// probeHeartbeat checks that a heartbeat endpoint was pinged within its interval
+// plus grace period. It returns nil while the endpoint is not overdue, as the
+// pings themselves are recorded as successful checks.
+func probeHeartbeat(ctx context.Context, endpoint *models.Endpoint) *database.HealthCheck {
+	lastPing, err := database.GetLastPing(endpoint.ID)
+	if err != nil {
+		return &database.HealthCheck{
+			Result: ResultError,
+			Error:  "Failed to load last ping: " + err.Error(),
+		}
+	}
+
+	if lastPing == nil {
+		return &database.HealthCheck{
+			Result: ResultError,
+			Error:  "No ping received yet",
+		}
+	}
+
+	deadline := lastPing.Add(time.Duration(endpoint.Interval+endpoint.GracePeriod) * time.Second)
+	if time.Now().Before(deadline) {
+		return nil
+	}
+	return &database.HealthCheck{
+		Result: ResultError,
+		Error:  fmt.Sprintf("No ping received since %s", lastPing.Format(time.RFC3339)),
+	}
+}
+
+// RecordHeartbeat records a ping of a heartbeat endpoint as a successful check.
+// body is stored with the check, truncated and with invalid UTF-8 and NUL
+// bytes removed, as any client may send one.
+func RecordHeartbeat(endpoint *models.Endpoint, body []byte) {
+	now := time.Now()
+	if err := database.RecordPing(endpoint.ID, now); err != nil {
+		log.Printf("Failed to record ping of endpoint %d: %v", endpoint.ID, err)
+	}
+	endpoint.LastPingAt = &now
+
+	processResult(endpoint, &database.HealthCheck{
+		Result:   ResultOK,
+		Response: truncateBody(body),
+	})
+}
@@ -0,0 +1,167 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"api-monitor/database"
+	"api-monitor/models"
+)
+
+const (
+	// maxResponseBodySize is the number of response body bytes kept with a health check
+	maxResponseBodySize = 4096
+	// maxAssertionBodySize is the number of response body bytes assertions are evaluated against
+	maxAssertionBodySize = 1 << 20
+	// maxRedirects is the number of redirects followed, as by the default client
+	maxRedirects = 10
+)
+
+// probeHTTP sends the configured request to an endpoint and evaluates the response.
+// Requests that fail before a response is received are retried.
+func probeHTTP(ctx context.Context, endpoint *models.Endpoint) *database.HealthCheck {
+	capture := &certificateCapture{}
+	client := newProbeClient(capture)
+
+	return withRetries(ctx, endpoint, func() (*database.HealthCheck, error) {
+		// Each attempt starts from the endpoint, whatever redirects the previous one followed
+		capture.setHost(requestHost(endpoint.URL))
+		check, _, err := doHTTPRequest(ctx, client, endpoint)
+		check.Certificate = capture.take()
+		return check, err
+	})
+}
+
+// newProbeClient returns a client recording the certificates of the hosts it
+// connects to in capture, including those it is redirected to
+func newProbeClient(capture *certificateCapture) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = capture.tlsConfig()
+	// Every check opens a new connection, so the handshake is timed and the certificate seen
+	transport.DisableKeepAlives = true
+
+	return &http.Client{
+		Timeout:   settings.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			capture.setHost(req.URL.Hostname())
+			return nil
+		},
+	}
+}
+
+// probeResponse is the part of a response kept for extracting values
+type probeResponse struct {
+	header http.Header
+	body   []byte
+}
+
+// doHTTPRequest performs a single probe request. The response is returned when
+// one was received. The returned error is set only when no response was
+// received and the request may be retried.
+func doHTTPRequest(ctx context.Context, client *http.Client, endpoint *models.Endpoint) (*database.HealthCheck, *probeResponse, error) {
+	method := endpoint.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if endpoint.Body != "" {
+		body = strings.NewReader(endpoint.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.URL, body)
+	if err != nil {
+		return &database.HealthCheck{
+			Result: ResultError,
+			Error:  "Invalid request: " + err.Error(),
+		}, nil, nil
+	}
+	for name, value := range endpoint.Headers {
+		req.Header.Set(name, value)
+	}
+	// Host has to be set on the request itself, the header is ignored
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+
+	trace, clientTrace := newRequestTrace()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), clientTrace))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		check := &database.HealthCheck{
+			Result: ResultError,
+			Error:  err.Error(),
+		}
+		check.SetTimings(trace.timings(time.Now()))
+		return check, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxAssertionBodySize))
+	end := time.Now()
+	elapsed := end.Sub(trace.start)
+	check := &database.HealthCheck{
+		Status:   resp.StatusCode,
+		Result:   ResultOK,
+		Response: truncateBody(respBody),
+	}
+	check.SetTimings(trace.timings(end))
+
+	if err != nil {
+		check.Result = ResultError
+		check.Error = "Failed to read response body: " + err.Error()
+	} else if !isExpectedStatus(resp.StatusCode, endpoint.ExpectedStatus) {
+		check.Result = ResultError
+		check.Error = fmt.Sprintf("Unexpected status code %d", resp.StatusCode)
+	} else if failed, reason := evaluateAssertions(endpoint.Assertions, resp, respBody, elapsed); failed != nil {
+		check.Result = ResultError
+		check.Error = "Assertion failed: " + reason
+		check.FailedAssertion = failed.String()
+	}
+
+	return check, &probeResponse{header: resp.Header, body: respBody}, nil
+}
+
+// isExpectedStatus reports whether a status code counts as healthy.
+// Any 2xx status is healthy unless a list of expected codes is configured.
+func isExpectedStatus(status int, expected []int64) bool {
+	if len(expected) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, code := range expected {
+		if int64(status) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateBody returns the part of a response body stored with a health check.
+// The body is cut at a rune boundary, invalid UTF-8 is replaced and NUL bytes
+// are dropped, as Postgres text columns reject both.
+func truncateBody(body []byte) string {
+	if len(body) > maxResponseBodySize {
+		body = body[:maxResponseBodySize]
+		// Drop a rune split by the limit rather than storing half of it
+		start := len(body) - 1
+		for start > 0 && len(body)-start < utf8.UTFMax && !utf8.RuneStart(body[start]) {
+			start--
+		}
+		if !utf8.FullRune(body[start:]) {
+			body = body[:start]
+		}
+	}
+	text := strings.ToValidUTF8(string(body), string(utf8.RuneError))
+	return strings.ReplaceAll(text, "\x00", "")
+}
@@ -0,0 +1,252 @@
+package monitor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"api-monitor/models"
+)
+
+func TestDoHTTPRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Version", "2")
+			io.WriteString(w, `{"status":"up","checks":[{"name":"db","ok":true}]}`)
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("X-Method", r.Method)
+			w.Header().Set("X-Token", r.Header.Get("X-Token"))
+			w.Write(body)
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+			io.WriteString(w, "done")
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		endpoint models.Endpoint
+		result   string
+		status   int
+		error    string
+		failed   string
+	}{
+		{
+			name:     "healthy",
+			endpoint: models.Endpoint{URL: server.URL + "/health"},
+			result:   ResultOK,
+			status:   http.StatusOK,
+		},
+		{
+			name:     "unexpected status",
+			endpoint: models.Endpoint{URL: server.URL + "/missing"},
+			result:   ResultError,
+			status:   http.StatusNotFound,
+			error:    "Unexpected status code 404",
+		},
+		{
+			name:     "expected status",
+			endpoint: models.Endpoint{URL: server.URL + "/missing", ExpectedStatus: []int64{404}},
+			result:   ResultOK,
+			status:   http.StatusNotFound,
+		},
+		{
+			name: "method, headers and body",
+			endpoint: models.Endpoint{
+				URL:     server.URL + "/echo",
+				Method:  http.MethodPost,
+				Headers: map[string]string{"X-Token": "secret"},
+				Body:    "ping",
+				Assertions: []models.Assertion{
+					{Type: models.AssertHeaderEquals, Property: "X-Method", Value: http.MethodPost},
+					{Type: models.AssertHeaderEquals, Property: "X-Token", Value: "secret"},
+					{Type: models.AssertBodyContains, Value: "ping"},
+				},
+			},
+			result: ResultOK,
+			status: http.StatusOK,
+		},
+		{
+			name: "passing assertions",
+			endpoint: models.Endpoint{
+				URL: server.URL + "/health",
+				Assertions: []models.Assertion{
+					{Type: models.AssertBodyContains, Value: `"up"`},
+					{Type: models.AssertBodyNotContains, Value: "error"},
+					{Type: models.AssertBodyRegex, Value: `"status":"\w+"`},
+					{Type: models.AssertJSONPathEquals, Property: "$.status", Value: "up"},
+					{Type: models.AssertJSONPathEquals, Property: "$.checks[0].ok", Value: "true"},
+					{Type: models.AssertJSONPathExists, Property: "$.checks[0].name"},
+					{Type: models.AssertHeaderEquals, Property: "X-Version", Value: "2"},
+					{Type: models.AssertResponseTimeMax, Value: "5000"},
+				},
+			},
+			result: ResultOK,
+			status: http.StatusOK,
+		},
+		{
+			name: "body does not contain",
+			endpoint: models.Endpoint{
+				URL:        server.URL + "/health",
+				Assertions: []models.Assertion{{Type: models.AssertBodyContains, Value: "down"}},
+			},
+			result: ResultError,
+			status: http.StatusOK,
+			error:  `Assertion failed: response body does not contain "down"`,
+			failed: `body_contains "down"`,
+		},
+		{
+			name: "json path mismatch",
+			endpoint: models.Endpoint{
+				URL:        server.URL + "/health",
+				Assertions: []models.Assertion{{Type: models.AssertJSONPathEquals, Property: "$.status", Value: "down"}},
+			},
+			result: ResultError,
+			status: http.StatusOK,
+			error:  `Assertion failed: $.status is "up", expected "down"`,
+			failed: `json_path_equals $.status "down"`,
+		},
+		{
+			name: "json path missing",
+			endpoint: models.Endpoint{
+				URL:        server.URL + "/health",
+				Assertions: []models.Assertion{{Type: models.AssertJSONPathExists, Property: "$.checks[1].name"}},
+			},
+			result: ResultError,
+			status: http.StatusOK,
+			error:  "Assertion failed: $.checks[1].name not found in response body",
+			failed: `json_path_exists $.checks[1].name ""`,
+		},
+		{
+			name: "not json",
+			endpoint: models.Endpoint{
+				URL:        server.URL + "/slow",
+				Assertions: []models.Assertion{{Type: models.AssertJSONPathExists, Property: "$.status"}},
+			},
+			result: ResultError,
+			status: http.StatusOK,
+			error:  "Assertion failed: response body is not valid JSON",
+			failed: `json_path_exists $.status ""`,
+		},
+		{
+			name: "header mismatch",
+			endpoint: models.Endpoint{
+				URL:        server.URL + "/health",
+				Assertions: []models.Assertion{{Type: models.AssertHeaderEquals, Property: "X-Version", Value: "3"}},
+			},
+			result: ResultError,
+			status: http.StatusOK,
+			error:  `Assertion failed: header X-Version is "2", expected "3"`,
+			failed: `header_equals X-Version "3"`,
+		},
+		{
+			name: "too slow",
+			endpoint: models.Endpoint{
+				URL:        server.URL + "/slow",
+				Assertions: []models.Assertion{{Type: models.AssertResponseTimeMax, Value: "10"}},
+			},
+			result: ResultError,
+			status: http.StatusOK,
+			failed: `response_time_max "10"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, resp, err := doHTTPRequest(context.Background(), server.Client(), &tt.endpoint)
+			if err != nil {
+				t.Fatalf("doHTTPRequest() error = %v", err)
+			}
+			if resp == nil {
+				t.Fatal("doHTTPRequest() returned no response")
+			}
+			if check.Result != tt.result {
+				t.Errorf("Result = %q, want %q (error %q)", check.Result, tt.result, check.Error)
+			}
+			if check.Status != tt.status {
+				t.Errorf("Status = %d, want %d", check.Status, tt.status)
+			}
+			if tt.error != "" && check.Error != tt.error {
+				t.Errorf("Error = %q, want %q", check.Error, tt.error)
+			}
+			if check.FailedAssertion != tt.failed {
+				t.Errorf("FailedAssertion = %q, want %q", check.FailedAssertion, tt.failed)
+			}
+		})
+	}
+}
+
+func TestDoHTTPRequestConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	check, resp, err := doHTTPRequest(context.Background(), http.DefaultClient, &models.Endpoint{URL: url})
+	if err == nil {
+		t.Fatal("doHTTPRequest() error = nil, want a retryable error")
+	}
+	if resp != nil {
+		t.Error("doHTTPRequest() returned a response for a failed connection")
+	}
+	if check.Result != ResultError || check.Error == "" {
+		t.Errorf("check = %+v, want an error result", check)
+	}
+}
+
+func TestTruncateBody(t *testing.T) {
+	// "é" is two bytes, so the limit falls in the middle of the last one
+	split := strings.Repeat("a", maxResponseBodySize-1) + "é"
+
+	tests := []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{
+			name: "text",
+			body: []byte("hello, wörld"),
+			want: "hello, wörld",
+		},
+		{
+			name: "binary",
+			body: []byte{'o', 'k', 0x00, 0xff, 0xfe, '!', 0x00},
+			want: "ok�!",
+		},
+		{
+			name: "rune split at limit",
+			body: []byte(split + "tail"),
+			want: strings.Repeat("a", maxResponseBodySize-1),
+		},
+		{
+			name: "rune ending at limit",
+			body: []byte(strings.Repeat("a", maxResponseBodySize-2) + "é" + "tail"),
+			want: strings.Repeat("a", maxResponseBodySize-2) + "é",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateBody(tt.body)
+			if got != tt.want {
+				t.Errorf("truncateBody() = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateBody() returned invalid UTF-8")
+			}
+			if strings.ContainsRune(got, 0) {
+				t.Errorf("truncateBody() kept a NUL byte")
+			}
+		})
+	}
+}
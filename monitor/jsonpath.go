@@ -0,0 +1,83 @@
+package monitor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathSegment is a single object key or array index of a JSON path
+type jsonPathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath parses a simple JSON path such as $.data.items[0].name or $['key']
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSON path %q must start with $", path)
+	}
+
+	var segments []jsonPathSegment
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("JSON path %q has an empty key", path)
+			}
+			segments = append(segments, jsonPathSegment{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("JSON path %q has an unterminated [", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, jsonPathSegment{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("JSON path %q has an invalid index %q", path, inner)
+			}
+			segments = append(segments, jsonPathSegment{index: index, isIndex: true})
+		default:
+			return nil, fmt.Errorf("JSON path %q is invalid at %q", path, rest)
+		}
+	}
+
+	return segments, nil
+}
+
+// lookupJSONPath resolves a parsed path against a decoded JSON document
+func lookupJSONPath(doc interface{}, segments []jsonPathSegment) (interface{}, bool) {
+	current := doc
+	for _, segment := range segments {
+		if segment.isIndex {
+			array, ok := current.([]interface{})
+			if !ok || segment.index >= len(array) {
+				return nil, false
+			}
+			current = array[segment.index]
+			continue
+		}
+
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[segment.key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
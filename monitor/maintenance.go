@@ -0,0 +1,192 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	// Embed the time zone database so that maintenance time zones resolve on minimal images
+	_ "time/tzdata"
+
+	"api-monitor/database"
+	"api-monitor/models"
+
+	"github.com/robfig/cron/v3"
+)
+
+// maintenanceWindow is a maintenance window with its parsed schedule
+type maintenanceWindow struct {
+	window   database.MaintenanceWindow
+	schedule cron.Schedule // nil for one-off windows
+	location *time.Location
+}
+
+var (
+	maintenanceWindows   = make(map[uint]*maintenanceWindow)
+	maintenanceWindowsMu sync.RWMutex
+)
+
+// LoadMaintenanceWindows loads the active maintenance windows from the database
+func LoadMaintenanceWindows() error {
+	windows, err := database.GetActiveMaintenanceWindows()
+	if err != nil {
+		return err
+	}
+
+	for _, w := range windows {
+		if err := PutMaintenanceWindow(w); err != nil {
+			log.Printf("Ignoring maintenance window %d: %v", w.ID, err)
+		}
+	}
+	log.Printf("Loaded %d maintenance windows", len(windows))
+	return nil
+}
+
+// PutMaintenanceWindow adds or replaces a maintenance window. Inactive windows are removed.
+func PutMaintenanceWindow(w database.MaintenanceWindow) error {
+	if !w.IsActive {
+		RemoveMaintenanceWindow(w.ID)
+		return nil
+	}
+
+	schedule, location, err := ParseMaintenanceSchedule(w.Cron, w.TimeZone)
+	if err != nil {
+		return err
+	}
+
+	maintenanceWindowsMu.Lock()
+	defer maintenanceWindowsMu.Unlock()
+	maintenanceWindows[w.ID] = &maintenanceWindow{window: w, schedule: schedule, location: location}
+	return nil
+}
+
+// RemoveMaintenanceWindow stops applying a maintenance window
+func RemoveMaintenanceWindow(id uint) {
+	maintenanceWindowsMu.Lock()
+	defer maintenanceWindowsMu.Unlock()
+	delete(maintenanceWindows, id)
+}
+
+// ParseMaintenanceSchedule parses the cron expression and time zone of a
+// recurring maintenance window. An empty expression returns a nil schedule.
+func ParseMaintenanceSchedule(expr, timeZone string) (cron.Schedule, *time.Location, error) {
+	location := time.UTC
+	if timeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return nil, nil, fmt.Errorf("unknown time zone %q", timeZone)
+		}
+	}
+
+	if expr == "" {
+		return nil, location, nil
+	}
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+	}
+	return schedule, location, nil
+}
+
+// activeAt reports whether the window is in effect at the given time
+func (m *maintenanceWindow) activeAt(at time.Time) bool {
+	w := m.window
+	if m.schedule == nil {
+		return w.StartsAt != nil && w.EndsAt != nil && !at.Before(*w.StartsAt) && at.Before(*w.EndsAt)
+	}
+
+	// The window is active if it started within the last Duration minutes
+	length := time.Duration(w.Duration) * time.Minute
+	start := m.schedule.Next(at.Add(-length).In(m.location))
+	return !start.After(at)
+}
+
+// matches reports whether the window applies to an endpoint
+func (m *maintenanceWindow) matches(endpoint *models.Endpoint) bool {
+	w := m.window
+	if w.OrganizationID != endpoint.OrganizationID {
+		return false
+	}
+	for _, id := range w.EndpointIDs {
+		if int(id) == endpoint.ID {
+			return true
+		}
+	}
+	for _, tag := range w.Tags {
+		for _, endpointTag := range endpoint.Tags {
+			if tag == endpointTag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ActiveMaintenance returns the maintenance window an endpoint is in at the given
+// time, nil if none. Windows that skip checks take precedence.
+func ActiveMaintenance(endpoint *models.Endpoint, at time.Time) *database.MaintenanceWindow {
+	maintenanceWindowsMu.RLock()
+	defer maintenanceWindowsMu.RUnlock()
+
+	var active *database.MaintenanceWindow
+	for _, m := range maintenanceWindows {
+		if !m.matches(endpoint) || !m.activeAt(at) {
+			continue
+		}
+		w := m.window
+		if w.Mode == database.MaintenanceSkip {
+			return &w
+		}
+		active = &w
+	}
+	return active
+}
+
+// MaintenanceActiveAt reports whether a maintenance window is in effect at the given time
+func MaintenanceActiveAt(w database.MaintenanceWindow, at time.Time) bool {
+	if !w.IsActive {
+		return false
+	}
+	schedule, location, err := ParseMaintenanceSchedule(w.Cron, w.TimeZone)
+	if err != nil {
+		return false
+	}
+	m := &maintenanceWindow{window: w, schedule: schedule, location: location}
+	return m.activeAt(at)
+}
+
+// ValidateMaintenanceWindow checks that a maintenance window is either a valid
+// one-off or a valid recurring window
+func ValidateMaintenanceWindow(w database.MaintenanceWindow) error {
+	recurring := w.Cron != ""
+	oneOff := w.StartsAt != nil || w.EndsAt != nil
+
+	switch {
+	case recurring && oneOff:
+		return errors.New("a window is either one-off (starts_at, ends_at) or recurring (cron, duration), not both")
+	case recurring:
+		if w.Duration <= 0 {
+			return errors.New("a recurring window needs a positive duration in minutes")
+		}
+	case oneOff:
+		if w.StartsAt == nil || w.EndsAt == nil || !w.EndsAt.After(*w.StartsAt) {
+			return errors.New("a one-off window needs starts_at before ends_at")
+		}
+	default:
+		return errors.New("a window needs starts_at and ends_at, or cron and duration")
+	}
+
+	if _, _, err := ParseMaintenanceSchedule(w.Cron, w.TimeZone); err != nil {
+		return err
+	}
+
+	if w.Mode != database.MaintenanceSkip && w.Mode != database.MaintenanceRecord {
+		return fmt.Errorf("invalid mode %q, must be %s or %s", w.Mode, database.MaintenanceSkip, database.MaintenanceRecord)
+	}
+	if len(w.EndpointIDs) == 0 && len(w.Tags) == 0 {
+		return errors.New("a window needs endpoint_ids or tags")
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"api-monitor/database"
+)
+
+func TestMaintenanceWindowActiveAt(t *testing.T) {
+	utc := func(month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(2026, month, day, hour, min, sec, 0, time.UTC)
+	}
+	startsAt, endsAt := utc(time.May, 4, 22, 0, 0), utc(time.May, 5, 1, 30, 0)
+	oneOff := database.MaintenanceWindow{StartsAt: &startsAt, EndsAt: &endsAt}
+	nightly := database.MaintenanceWindow{Cron: "0 2 * * *", Duration: 60}
+	overMidnight := database.MaintenanceWindow{Cron: "0 23 * * *", Duration: 120}
+	newYork := database.MaintenanceWindow{Cron: "0 2 * * *", Duration: 60, TimeZone: "America/New_York"}
+	// Berlin switches to summer time on 29 March 2026, moving 09:00 from 08:00 to 07:00 UTC
+	berlin := database.MaintenanceWindow{Cron: "0 9 * * *", Duration: 30, TimeZone: "Europe/Berlin"}
+	zeroLength := database.MaintenanceWindow{Cron: "0 2 * * *", Duration: 0}
+
+	tests := []struct {
+		name   string
+		window database.MaintenanceWindow
+		at     time.Time
+		want   bool
+	}{
+		{"one-off before start", oneOff, utc(time.May, 4, 21, 59, 59), false},
+		{"one-off at start", oneOff, startsAt, true},
+		{"one-off before end", oneOff, utc(time.May, 5, 1, 29, 59), true},
+		{"one-off at end", oneOff, endsAt, false},
+		{"one-off without end", database.MaintenanceWindow{StartsAt: &startsAt}, utc(time.May, 5, 0, 0, 0), false},
+
+		{"cron before start", nightly, utc(time.May, 5, 1, 59, 59), false},
+		{"cron at start", nightly, utc(time.May, 5, 2, 0, 0), true},
+		{"cron before end", nightly, utc(time.May, 5, 2, 59, 59), true},
+		{"cron at end", nightly, utc(time.May, 5, 3, 0, 0), false},
+		{"cron over midnight", overMidnight, utc(time.May, 6, 0, 30, 0), true},
+		{"cron over midnight at end", overMidnight, utc(time.May, 6, 1, 0, 0), false},
+
+		{"time zone at start", newYork, utc(time.January, 15, 7, 0, 0), true},
+		{"time zone at utc start", newYork, utc(time.January, 15, 2, 0, 0), false},
+		{"time zone in summer", newYork, utc(time.July, 15, 6, 30, 0), true},
+		{"time zone in summer at winter start", newYork, utc(time.July, 15, 7, 0, 0), false},
+
+		{"dst before switch", berlin, utc(time.March, 28, 8, 10, 0), true},
+		{"dst before switch at summer start", berlin, utc(time.March, 28, 7, 10, 0), false},
+		{"dst after switch", berlin, utc(time.March, 29, 7, 10, 0), true},
+		{"dst after switch at winter start", berlin, utc(time.March, 29, 8, 10, 0), false},
+
+		{"zero duration at start", zeroLength, utc(time.May, 5, 2, 0, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, location, err := ParseMaintenanceSchedule(tt.window.Cron, tt.window.TimeZone)
+			if err != nil {
+				t.Fatalf("ParseMaintenanceSchedule() error = %v", err)
+			}
+			m := &maintenanceWindow{window: tt.window, schedule: schedule, location: location}
+			if got := m.activeAt(tt.at); got != tt.want {
+				t.Errorf("activeAt(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMaintenanceWindowDuration(t *testing.T) {
+	w := database.MaintenanceWindow{Cron: "0 2 * * *", Mode: database.MaintenanceSkip, Tags: []string{"db"}}
+	for _, duration := range []int{0, -5} {
+		w.Duration = duration
+		if err := ValidateMaintenanceWindow(w); err == nil {
+			t.Errorf("ValidateMaintenanceWindow() with duration %d error = nil, want an error", duration)
+		}
+	}
+
+	w.Duration = 30
+	if err := ValidateMaintenanceWindow(w); err != nil {
+		t.Errorf("ValidateMaintenanceWindow() error = %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/models"
+)
+
+// prober checks an endpoint of one monitor type and returns the unsaved result,
+// or nil if there is nothing to record
+type prober func(ctx context.Context, endpoint *models.Endpoint) *database.HealthCheck
+
+// probers maps monitor types to their probe
+var probers = map[string]prober{
+	models.TypeHTTP:      probeHTTP,
+	models.TypeTCP:       probeTCP,
+	models.TypeDNS:       probeDNS,
+	models.TypeHeartbeat: probeHeartbeat,
+	models.TypeGRPC:      probeGRPC,
+
+	models.TypeTransaction: probeTransaction,
+}
+
+// probe runs the prober for the endpoint's monitor type
+func probe(ctx context.Context, endpoint *models.Endpoint) *database.HealthCheck {
+	endpointType := endpoint.Type
+	if endpointType == "" {
+		endpointType = models.TypeHTTP
+	}
+
+	p, ok := probers[endpointType]
+	if !ok {
+		return &database.HealthCheck{
+			Result: ResultError,
+			Error:  "Unknown monitor type " + endpointType,
+		}
+	}
+	return p(ctx, endpoint)
+}
+
+// withRetries runs attempt until it succeeds or the configured number of attempts
+// is used up. attempt returns an error only when the failure may be retried.
+func withRetries(ctx context.Context, endpoint *models.Endpoint, attempt func() (*database.HealthCheck, error)) *database.HealthCheck {
+	for i := 0; ; i++ {
+		check, err := attempt()
+		if err == nil || i >= settings.Retries-1 {
+			return check
+		}
+
+		log.Printf("Retry %d/%d for endpoint %s: %v", i+1, settings.Retries, endpoint.URL, err)
+		select {
+		case <-time.After(settings.RetryDelay):
+		case <-ctx.Done():
+			return check
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package monitor
+
+import "api-monitor/config"
+
+// settings holds the probe timeouts, retries and failure threshold
+var settings = config.Default().Checker
+
+// Configure sets the probe timeouts, retries and failure threshold
+func Configure(cfg config.CheckerConfig) {
+	settings = cfg
+}
@@ -0,0 +1,224 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"api-monitor/database"
+	"api-monitor/models"
+	"api-monitor/notifier"
+)
+
+// endpointState tracks the failure streak, open incident, certificate, flapping and status of a single endpoint
+type endpointState struct {
+	mu                  sync.Mutex
+	loaded              bool
+	consecutiveFailures int
+	incidentID          uint
+
+	certLoaded bool
+	cert       *models.Certificate // Last stored certificate
+
+	historyLoaded bool
+	history       []bool // Whether each of the recent checks passed, oldest first
+	flapping      bool   // Alerts are suppressed while set
+
+	downPending     bool                // The DOWN alert of the open incident was suppressed by flapping
+	recoveryPending *suppressedRecovery // Recovery alert suppressed by flapping after its DOWN alert was sent
+
+	status string // Last published status, empty until the first check
+}
+
+// suppressedRecovery is a recovery alert held back until an endpoint stops flapping
+type suppressedRecovery struct {
+	incidentID uint
+	event      notifier.Event
+}
+
+var (
+	states   = make(map[int]*endpointState)
+	statesMu sync.Mutex
+)
+
+// Alert senders, replaced in tests
+var (
+	dispatch         = notifier.Dispatch
+	dispatchIncident = notifier.DispatchIncident
+	escalate         = notifier.Escalate
+)
+
+// stateFor returns the tracked state of an endpoint, creating it if needed
+func stateFor(endpointID int) *endpointState {
+	statesMu.Lock()
+	defer statesMu.Unlock()
+
+	state, ok := states[endpointID]
+	if !ok {
+		state = &endpointState{}
+		states[endpointID] = state
+	}
+	return state
+}
+
+// ForgetEndpoint drops the tracked state of a removed endpoint
+func ForgetEndpoint(endpointID int) {
+	statesMu.Lock()
+	defer statesMu.Unlock()
+	delete(states, endpointID)
+}
+
+// trackFailures counts consecutive failed checks, opens an incident when the
+// endpoint crosses the failure threshold and resolves it once the endpoint recovers.
+// Incidents of endpoints with an escalation policy are alerted through the policy.
+// Alerts are not sent while the endpoint is flapping, but held back until it
+// stops by releaseSuppressedAlerts.
+func trackFailures(endpoint *models.Endpoint, check *database.HealthCheck, healthy bool) {
+	threshold := failureThreshold(endpoint)
+	state := stateFor(endpoint.ID)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	// Pick up incidents left open by a previous run
+	if !state.loaded {
+		incident, err := database.GetUnresolvedIncident(endpoint.ID)
+		if err != nil {
+			log.Printf("Failed to load open incident for endpoint %d: %v", endpoint.ID, err)
+			return
+		}
+		if incident != nil {
+			state.incidentID = incident.ID
+			state.consecutiveFailures = threshold
+			// Whether its alert went out before the restart is unknown. It is
+			// assumed to have been sent, unless the endpoint is flapping and so
+			// could have held it back: then it is sent once flapping stops,
+			// rather than risk the incident staying silent.
+			state.downPending = state.flapping
+		}
+		state.loaded = true
+	}
+
+	if healthy {
+		// Reset consecutive failures counter on successful response
+		state.consecutiveFailures = 0
+		if state.incidentID != 0 {
+			if err := database.ResolveIncident(state.incidentID, check); err != nil {
+				log.Printf("Failed to resolve incident %d: %v", state.incidentID, err)
+				return
+			}
+			log.Printf("Endpoint %s recovered, resolved incident %d", endpoint.URL, state.incidentID)
+			publishIncident(endpoint, state.incidentID, database.IncidentResolved, "Endpoint recovered")
+			event := newEvent(notifier.EventRecovered, endpoint, check)
+			switch {
+			case state.downPending:
+				// Nobody was told about the incident, so there is no recovery to announce
+			case state.flapping:
+				state.recoveryPending = &suppressedRecovery{incidentID: state.incidentID, event: event}
+			default:
+				go dispatchIncident(state.incidentID, event)
+			}
+			state.incidentID = 0
+			state.downPending = false
+		}
+		return
+	}
+
+	state.consecutiveFailures++
+	if state.consecutiveFailures < threshold || state.incidentID != 0 {
+		return
+	}
+
+	log.Printf("WARNING: Endpoint %s has failed %d consecutive checks!", endpoint.URL, state.consecutiveFailures)
+
+	checks, err := database.GetRecentHealthChecks(endpoint.ID, threshold)
+	if err != nil {
+		log.Printf("Failed to load triggering checks for endpoint %d: %v", endpoint.ID, err)
+	}
+	// The outage started with the first of the failed checks, which come newest first
+	startedAt := check.CheckedAt
+	if len(checks) > 0 {
+		startedAt = checks[len(checks)-1].CheckedAt
+	}
+
+	incident := &database.Incident{
+		EndpointID:     endpoint.ID,
+		OrganizationID: endpoint.OrganizationID,
+		UserID:         endpoint.UserID,
+		Cause:          failureCause(check, threshold),
+		StartedAt:      startedAt,
+
+		EscalationPolicyID: endpoint.EscalationPolicyID,
+	}
+	if err := database.OpenIncident(incident, checks); err != nil {
+		log.Printf("Failed to open incident for endpoint %d: %v", endpoint.ID, err)
+		return
+	}
+	state.incidentID = incident.ID
+	publishIncident(endpoint, incident.ID, database.IncidentOpen, incident.Cause)
+	if state.flapping {
+		state.downPending = true
+		return
+	}
+	alertDown(endpoint, incident.ID, incident.EscalationPolicyID, check)
+}
+
+// releaseSuppressedAlerts sends the alerts held back while an endpoint was
+// flapping: the DOWN alert of an incident that is still open, and the recovery
+// alert of an incident whose DOWN alert had been sent
+func releaseSuppressedAlerts(endpoint *models.Endpoint, check *database.HealthCheck) {
+	state := stateFor(endpoint.ID)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if recovery := state.recoveryPending; recovery != nil {
+		state.recoveryPending = nil
+		go dispatchIncident(recovery.incidentID, recovery.event)
+	}
+
+	if state.downPending && state.incidentID != 0 {
+		state.downPending = false
+		log.Printf("Endpoint %s stopped flapping with incident %d open, sending its alert", endpoint.URL, state.incidentID)
+		alertDown(endpoint, state.incidentID, endpoint.EscalationPolicyID, check)
+	}
+}
+
+// alertDown sends the DOWN alert of an incident, through its escalation policy if it has one
+func alertDown(endpoint *models.Endpoint, incidentID uint, policyID *uint, check *database.HealthCheck) {
+	if policyID != nil {
+		go escalate(incidentID)
+	} else {
+		go dispatch(endpoint.OrganizationID, newEvent(notifier.EventDown, endpoint, check))
+	}
+}
+
+// failureThreshold returns the number of consecutive failed checks after which
+// an incident is opened. A missed heartbeat already allows for a grace period.
+func failureThreshold(endpoint *models.Endpoint) int {
+	if endpoint.Type == models.TypeHeartbeat {
+		return 1
+	}
+	return settings.FailureThreshold
+}
+
+// failureCause describes why a check failed
+func failureCause(check *database.HealthCheck, threshold int) string {
+	if threshold == 1 {
+		return check.Error
+	}
+	if check.Error != "" {
+		return fmt.Sprintf("%d consecutive failed checks: %s", threshold, check.Error)
+	}
+	return fmt.Sprintf("%d consecutive failed checks: HTTP %d", threshold, check.Status)
+}
+
+// newEvent builds a notification event from a health check
+func newEvent(eventType notifier.EventType, endpoint *models.Endpoint, check *database.HealthCheck) notifier.Event {
+	return notifier.Event{
+		Type:       eventType,
+		EndpointID: endpoint.ID,
+		URL:        endpoint.URL,
+		StatusCode: check.Status,
+		Error:      check.Error,
+		Time:       check.CheckedAt,
+	}
+}
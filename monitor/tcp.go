@@ -0,0 +1,143 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/models"
+)
+
+// maxBannerSize is the number of bytes read from a TCP endpoint while waiting for the expected reply
+const maxBannerSize = 4096
+
+// probeTCP connects to the host:port of an endpoint, optionally sends data and
+// checks the reply. Connections that cannot be established are retried.
+func probeTCP(ctx context.Context, endpoint *models.Endpoint) *database.HealthCheck {
+	return withRetries(ctx, endpoint, func() (*database.HealthCheck, error) {
+		return doTCPCheck(ctx, endpoint)
+	})
+}
+
+// doTCPCheck performs a single connection attempt. The returned error is set
+// only when no connection was established and the attempt may be retried.
+func doTCPCheck(ctx context.Context, endpoint *models.Endpoint) (*database.HealthCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, settings.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	var timings models.Timings
+	fail := func(msg string) *database.HealthCheck {
+		timings.Total = time.Since(start).Milliseconds()
+		check := &database.HealthCheck{
+			Result: ResultError,
+			Error:  msg,
+		}
+		check.SetTimings(timings)
+		return check
+	}
+
+	host, port, err := net.SplitHostPort(endpoint.URL)
+	if err != nil {
+		return fail("Invalid address: " + err.Error()), nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	timings.DNSLookup = time.Since(start).Milliseconds()
+	if err != nil {
+		return fail(err.Error()), err
+	}
+
+	connectStart := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addrs[0], port))
+	timings.TCPConnect = time.Since(connectStart).Milliseconds()
+	if err != nil {
+		return fail(err.Error()), err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if endpoint.Send != "" {
+		if _, err := conn.Write([]byte(endpoint.Send)); err != nil {
+			return fail("Failed to send data: " + err.Error()), nil
+		}
+	}
+
+	var reply []byte
+	if endpoint.Expect != "" {
+		var firstByte time.Time
+		reply, firstByte, err = readUntil(conn, endpoint.Expect)
+		if !firstByte.IsZero() {
+			timings.TimeToFirstByte = firstByte.Sub(start).Milliseconds()
+		}
+		if err != nil {
+			check := fail(fmt.Sprintf("Expected reply containing %q: %v", endpoint.Expect, err))
+			check.Response = quoteReply(reply)
+			return check, nil
+		}
+	}
+
+	timings.Total = time.Since(start).Milliseconds()
+	check := &database.HealthCheck{
+		Result:   ResultOK,
+		Response: quoteReply(reply),
+	}
+	check.SetTimings(timings)
+	return check, nil
+}
+
+// quoteReply returns the part of a TCP reply stored with a health check as a Go
+// quoted string. Banners are often binary, so non-printable bytes are escaped
+// rather than stored as is.
+func quoteReply(reply []byte) string {
+	if len(reply) == 0 {
+		return ""
+	}
+	if len(reply) > maxResponseBodySize {
+		reply = reply[:maxResponseBodySize]
+	}
+	return strconv.Quote(string(reply))
+}
+
+// readUntil reads from conn until the data contains expect, the connection is
+// closed or maxBannerSize bytes were read. It also returns when the first byte arrived.
+func readUntil(conn net.Conn, expect string) ([]byte, time.Time, error) {
+	var (
+		data      []byte
+		firstByte time.Time
+		buf       = make([]byte, 512)
+	)
+	for len(data) < maxBannerSize {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			if firstByte.IsZero() {
+				firstByte = time.Now()
+			}
+			data = append(data, buf[:n]...)
+			if strings.Contains(string(data), expect) {
+				return data, firstByte, nil
+			}
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return data, firstByte, errors.New("timed out")
+			}
+			if err == io.EOF {
+				return data, firstByte, errors.New("connection closed")
+			}
+			return data, firstByte, err
+		}
+	}
+	return data, firstByte, errors.New("not found in reply")
+}
@@ -0,0 +1,43 @@
+package monitor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"api-monitor/models"
+)
+
+func TestDoTCPCheckBinaryBanner(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Shaped like a MySQL server greeting, which contains NUL bytes
+	banner := []byte("J\x00\x00\x00\x0a8.0.36\x00\xff\xfe")
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(banner)
+	}()
+
+	check, err := doTCPCheck(context.Background(), &models.Endpoint{
+		Type:   models.TypeTCP,
+		URL:    listener.Addr().String(),
+		Expect: "8.0",
+	})
+	if err != nil {
+		t.Fatalf("doTCPCheck() error = %v", err)
+	}
+	if check.Result != ResultOK {
+		t.Fatalf("Result = %q, want %q (error %q)", check.Result, ResultOK, check.Error)
+	}
+	if want := `"J\x00\x00\x00\n8.0.36\x00\xff\xfe"`; check.Response != want {
+		t.Errorf("Response = %s, want %s", check.Response, want)
+	}
+}
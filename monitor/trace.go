@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"api-monitor/models"
+)
+
+// requestTrace collects the phase timings of a single HTTP request
+type requestTrace struct {
+	mu        sync.Mutex
+	start     time.Time
+	dnsStart  time.Time
+	dnsDone   time.Time
+	connStart time.Time
+	connDone  time.Time
+	tlsStart  time.Time
+	tlsDone   time.Time
+	firstByte time.Time
+}
+
+// newRequestTrace starts timing a request and returns the hooks to attach to it
+func newRequestTrace() (*requestTrace, *httptrace.ClientTrace) {
+	t := &requestTrace{start: time.Now()}
+	return t, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.mark(&t.dnsDone) },
+		ConnectStart: func(string, string) {
+			t.markFirst(&t.connStart)
+		},
+		ConnectDone: func(string, string, error) { t.mark(&t.connDone) },
+		TLSHandshakeStart: func() {
+			t.mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) { t.mark(&t.tlsDone) },
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte)
+		},
+	}
+}
+
+// mark records the current time
+func (t *requestTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	*at = time.Now()
+	t.mu.Unlock()
+}
+
+// markFirst records the current time unless a time was already recorded.
+// Dual-stack dials may start several connection attempts.
+func (t *requestTrace) markFirst(at *time.Time) {
+	t.mu.Lock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+	t.mu.Unlock()
+}
+
+// timings returns the phase durations in milliseconds, measured up to end.
+// Phases that did not happen, e.g. on a reused connection, are zero.
+func (t *requestTrace) timings(end time.Time) models.Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timings := models.Timings{
+		DNSLookup:    between(t.dnsStart, t.dnsDone),
+		TCPConnect:   between(t.connStart, t.connDone),
+		TLSHandshake: between(t.tlsStart, t.tlsDone),
+		Total:        end.Sub(t.start).Milliseconds(),
+	}
+	if !t.firstByte.IsZero() {
+		timings.TimeToFirstByte = t.firstByte.Sub(t.start).Milliseconds()
+	}
+	return timings
+}
+
+// between returns the milliseconds from start to end, or zero if either is unset
+func between(start, end time.Time) int64 {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start).Milliseconds()
+}
@@ -0,0 +1,272 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"api-monitor/database"
+	"api-monitor/models"
+)
+
+// templateVariable matches a {{variable}} reference in a step
+var templateVariable = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// variableName matches valid extraction variable names
+var variableName = regexp.MustCompile(`^\w+$`)
+
+// probeTransaction runs the steps of a transaction endpoint in order, passing
+// extracted values and cookies on to later steps. The run stops at the first
+// failing step. Steps are not retried, as they may not be idempotent.
+func probeTransaction(ctx context.Context, endpoint *models.Endpoint) *database.HealthCheck {
+	jar, _ := cookiejar.New(nil)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{
+		Timeout:   settings.Timeout,
+		Transport: transport,
+		Jar:       jar,
+	}
+
+	check := &database.HealthCheck{Result: ResultOK}
+	var total models.Timings
+	vars := make(map[string]string)
+
+	for i, step := range endpoint.Steps {
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step %d", i+1)
+		}
+
+		stepCheck, resp := runStep(ctx, client, step, vars)
+		timings := stepCheck.Timings()
+		total.DNSLookup += timings.DNSLookup
+		total.TCPConnect += timings.TCPConnect
+		total.TLSHandshake += timings.TLSHandshake
+		total.TimeToFirstByte += timings.TimeToFirstByte
+		total.Total += timings.Total
+
+		if stepCheck.Result == ResultOK {
+			if err := extractValues(step.Extract, resp, vars); err != nil {
+				stepCheck.Result = ResultError
+				stepCheck.Error = err.Error()
+			}
+		}
+
+		check.Steps = append(check.Steps, models.StepResult{
+			Name:            name,
+			Status:          stepCheck.Status,
+			Result:          stepCheck.Result,
+			Error:           stepCheck.Error,
+			FailedAssertion: stepCheck.FailedAssertion,
+			Timings:         timings,
+		})
+		check.Status = stepCheck.Status
+		check.Response = stepCheck.Response
+
+		if stepCheck.Result != ResultOK {
+			check.Result = ResultError
+			check.Error = fmt.Sprintf("Step %d (%s): %s", i+1, name, stepCheck.Error)
+			check.FailedAssertion = stepCheck.FailedAssertion
+			break
+		}
+	}
+
+	check.SetTimings(total)
+	return check
+}
+
+// runStep sends the request of a single step with variables substituted
+func runStep(ctx context.Context, client *http.Client, step models.TransactionStep, vars map[string]string) (*database.HealthCheck, *probeResponse) {
+	request := &models.Endpoint{
+		Method:         step.Method,
+		ExpectedStatus: step.ExpectedStatus,
+	}
+
+	var err error
+	if request.URL, err = expandURL(step.URL, vars); err == nil {
+		request.Body, err = expandVariables(step.Body, vars)
+	}
+	if err == nil && len(step.Headers) > 0 {
+		request.Headers = make(map[string]string, len(step.Headers))
+		for name, value := range step.Headers {
+			if request.Headers[name], err = expandVariables(value, vars); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil && len(step.Assertions) > 0 {
+		request.Assertions = make([]models.Assertion, len(step.Assertions))
+		for i, a := range step.Assertions {
+			a.Value, err = expandVariables(a.Value, vars)
+			if err != nil {
+				break
+			}
+			request.Assertions[i] = a
+		}
+	}
+	if err != nil {
+		return &database.HealthCheck{Result: ResultError, Error: err.Error()}, nil
+	}
+
+	check, resp, _ := doHTTPRequest(ctx, client, request)
+	return check, resp
+}
+
+// expandVariables replaces {{variable}} references with extracted values as they are
+func expandVariables(s string, vars map[string]string) (string, error) {
+	return expandEscaped(s, vars, func(value string) string { return value })
+}
+
+// expandURL replaces {{variable}} references in a URL with extracted values
+// escaped for their place in it, so that a value cannot change the structure
+// of the URL: in the path, each part between slashes is escaped as a path
+// segment, and in the query string values are escaped as query values.
+func expandURL(s string, vars map[string]string) (string, error) {
+	path, query, hasQuery := strings.Cut(s, "?")
+	path, err := expandEscaped(path, vars, escapePath)
+	if err != nil || !hasQuery {
+		return path, err
+	}
+	query, err = expandEscaped(query, vars, url.QueryEscape)
+	if err != nil {
+		return "", err
+	}
+	return path + "?" + query, nil
+}
+
+// escapePath escapes a value for a URL path, keeping its slashes
+func escapePath(value string) string {
+	segments := strings.Split(value, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// expandEscaped replaces {{variable}} references with extracted values passed through escape
+func expandEscaped(s string, vars map[string]string, escape func(string) string) (string, error) {
+	var missing string
+	expanded := templateVariable.ReplaceAllStringFunc(s, func(ref string) string {
+		name := templateVariable.FindStringSubmatch(ref)[1]
+		value, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return escape(value)
+	})
+	if missing != "" {
+		return "", fmt.Errorf("undefined variable %q", missing)
+	}
+	return expanded, nil
+}
+
+// extractValues stores the values captured from a response in vars
+func extractValues(extractions []models.Extraction, resp *probeResponse, vars map[string]string) error {
+	var doc interface{}
+	decoded := false
+
+	for _, e := range extractions {
+		var value string
+		found := false
+
+		switch e.Source {
+		case models.ExtractJSONPath:
+			if !decoded {
+				if err := json.Unmarshal(resp.body, &doc); err != nil {
+					return fmt.Errorf("cannot extract %s, response body is not valid JSON", e.Variable)
+				}
+				decoded = true
+			}
+			segments, err := parseJSONPath(e.Property)
+			if err != nil {
+				return err
+			}
+			var v interface{}
+			if v, found = lookupJSONPath(doc, segments); found {
+				value = jsonValueString(v)
+			}
+		case models.ExtractHeader:
+			value = resp.header.Get(e.Property)
+			found = value != ""
+		case models.ExtractRegex:
+			re, err := regexp.Compile(e.Property)
+			if err != nil {
+				return err
+			}
+			if m := re.FindSubmatch(resp.body); m != nil {
+				found = true
+				value = string(m[0])
+				if len(m) > 1 {
+					value = string(m[1])
+				}
+			}
+		default:
+			return fmt.Errorf("unknown extraction source %q", e.Source)
+		}
+
+		if !found {
+			return fmt.Errorf("cannot extract %s, %s %q not found in response", e.Variable, e.Source, e.Property)
+		}
+		vars[e.Variable] = value
+	}
+	return nil
+}
+
+// ValidateTransaction checks that the extractions of a transaction are well
+// formed and that steps only reference variables extracted by earlier steps
+func ValidateTransaction(steps []models.TransactionStep) error {
+	defined := make(map[string]bool)
+
+	for i, step := range steps {
+		refs := []string{step.URL, step.Body}
+		for _, value := range step.Headers {
+			refs = append(refs, value)
+		}
+		for _, a := range step.Assertions {
+			refs = append(refs, a.Value)
+		}
+		for _, ref := range refs {
+			for _, m := range templateVariable.FindAllStringSubmatch(ref, -1) {
+				if !defined[m[1]] {
+					return fmt.Errorf("step %d uses variable %q before it is extracted", i+1, m[1])
+				}
+			}
+		}
+
+		for _, e := range step.Extract {
+			if !variableName.MatchString(e.Variable) {
+				return fmt.Errorf("step %d: invalid variable name %q", i+1, e.Variable)
+			}
+			switch e.Source {
+			case models.ExtractJSONPath:
+				if _, err := parseJSONPath(e.Property); err != nil {
+					return fmt.Errorf("step %d: %v", i+1, err)
+				}
+			case models.ExtractHeader:
+				if strings.TrimSpace(e.Property) == "" {
+					return fmt.Errorf("step %d: header extraction of %s requires a header name", i+1, e.Variable)
+				}
+			case models.ExtractRegex:
+				if _, err := regexp.Compile(e.Property); err != nil {
+					return fmt.Errorf("step %d: invalid regular expression %q: %v", i+1, e.Property, err)
+				}
+			default:
+				return fmt.Errorf("step %d: unknown extraction source %q", i+1, e.Source)
+			}
+			defined[e.Variable] = true
+		}
+	}
+	return nil
+}
+
+// StripVariables replaces {{variable}} references so that a step URL can be validated
+func StripVariables(s string) string {
+	return templateVariable.ReplaceAllString(s, "x")
+}
@@ -0,0 +1,255 @@
+package monitor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"api-monitor/models"
+)
+
+func TestExpandVariables(t *testing.T) {
+	vars := map[string]string{"token": "a&b c", "path": "/orders/1 2", "id": "7?x=1"}
+
+	tests := []struct {
+		name   string
+		expand func(string, map[string]string) (string, error)
+		s      string
+		want   string
+		error  string
+	}{
+		{"raw value", expandVariables, "Bearer {{token}}", "Bearer a&b c", ""},
+		{"spaces in reference", expandVariables, "{{ token }}/{{id}}", "a&b c/7?x=1", ""},
+		{"no references", expandVariables, `{"user": "monitor"}`, `{"user": "monitor"}`, ""},
+		{"undefined variable", expandVariables, "{{token}} {{session}}", "", `undefined variable "session"`},
+		{"path segment", expandURL, "https://api.example.com/orders/{{id}}", "https://api.example.com/orders/7%3Fx=1", ""},
+		{"path keeps slashes", expandURL, "https://api.example.com{{path}}", "https://api.example.com/orders/1%202", ""},
+		{"query value", expandURL, "https://api.example.com/search?q={{token}}&id={{id}}", "https://api.example.com/search?q=a%26b+c&id=7%3Fx%3D1", ""},
+		{"undefined variable in path", expandURL, "https://api.example.com/{{session}}?q={{token}}", "", `undefined variable "session"`},
+		{"undefined variable in query", expandURL, "https://api.example.com/{{id}}?q={{session}}", "", `undefined variable "session"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.expand(tt.s, vars)
+			if tt.error != "" {
+				if err == nil || err.Error() != tt.error {
+					t.Errorf("error = %v, want %q", err, tt.error)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractValues(t *testing.T) {
+	resp := &probeResponse{
+		header: http.Header{"Location": {"/orders/42"}},
+		body:   []byte(`{"token":"abc","user":{"id":7},"items":[{"sku":"X1"}]}`),
+	}
+
+	tests := []struct {
+		name       string
+		extraction models.Extraction
+		resp       *probeResponse
+		want       string
+		error      string
+	}{
+		{"json string", models.Extraction{Variable: "v", Source: models.ExtractJSONPath, Property: "$.token"}, resp, "abc", ""},
+		{"json number", models.Extraction{Variable: "v", Source: models.ExtractJSONPath, Property: "$.user.id"}, resp, "7", ""},
+		{"json array element", models.Extraction{Variable: "v", Source: models.ExtractJSONPath, Property: "$.items[0].sku"}, resp, "X1", ""},
+		{"json missing", models.Extraction{Variable: "v", Source: models.ExtractJSONPath, Property: "$.session"}, resp, "", `cannot extract v, json_path "$.session" not found in response`},
+		{"not json", models.Extraction{Variable: "v", Source: models.ExtractJSONPath, Property: "$.token"}, &probeResponse{body: []byte("ok")}, "", "cannot extract v, response body is not valid JSON"},
+		{"header", models.Extraction{Variable: "v", Source: models.ExtractHeader, Property: "location"}, resp, "/orders/42", ""},
+		{"header missing", models.Extraction{Variable: "v", Source: models.ExtractHeader, Property: "X-Request-Id"}, resp, "", `cannot extract v, header "X-Request-Id" not found in response`},
+		{"regex group", models.Extraction{Variable: "v", Source: models.ExtractRegex, Property: `"token":"(\w+)"`}, resp, "abc", ""},
+		{"regex whole match", models.Extraction{Variable: "v", Source: models.ExtractRegex, Property: `X\d`}, resp, "X1", ""},
+		{"regex no match", models.Extraction{Variable: "v", Source: models.ExtractRegex, Property: `Y\d`}, resp, "", `cannot extract v, regex "Y\\d" not found in response`},
+		{"unknown source", models.Extraction{Variable: "v", Source: "cookie", Property: "session"}, resp, "", `unknown extraction source "cookie"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := make(map[string]string)
+			err := extractValues([]models.Extraction{tt.extraction}, tt.resp, vars)
+			if tt.error != "" {
+				if err == nil || err.Error() != tt.error {
+					t.Errorf("error = %v, want %q", err, tt.error)
+				}
+				if _, ok := vars["v"]; ok {
+					t.Error("variable set by a failed extraction")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if vars["v"] != tt.want {
+				t.Errorf("v = %q, want %q", vars["v"], tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTransaction(t *testing.T) {
+	login := models.TransactionStep{
+		URL:     "https://api.example.com/login",
+		Extract: []models.Extraction{{Variable: "token", Source: models.ExtractJSONPath, Property: "$.token"}},
+	}
+	extracting := func(e models.Extraction) []models.TransactionStep {
+		return []models.TransactionStep{{URL: "https://api.example.com", Extract: []models.Extraction{e}}}
+	}
+
+	tests := []struct {
+		name  string
+		steps []models.TransactionStep
+		error string
+	}{
+		{
+			name: "variables used after extraction",
+			steps: []models.TransactionStep{login, {
+				URL:        "https://api.example.com/orders?token={{token}}",
+				Headers:    map[string]string{"Authorization": "Bearer {{token}}"},
+				Body:       `{"token": "{{token}}"}`,
+				Assertions: []models.Assertion{{Type: models.AssertBodyContains, Value: "{{token}}"}},
+			}},
+		},
+		{
+			name:  "undefined variable in url",
+			steps: []models.TransactionStep{login, {URL: "https://api.example.com/{{order}}"}},
+			error: `step 2 uses variable "order" before it is extracted`,
+		},
+		{
+			name:  "undefined variable in header",
+			steps: []models.TransactionStep{{URL: "https://api.example.com", Headers: map[string]string{"Authorization": "{{token}}"}}},
+			error: `step 1 uses variable "token" before it is extracted`,
+		},
+		{
+			name: "variable used by the step extracting it",
+			steps: []models.TransactionStep{{
+				URL:     "https://api.example.com/{{token}}",
+				Extract: login.Extract,
+			}},
+			error: `step 1 uses variable "token" before it is extracted`,
+		},
+		{
+			name:  "invalid variable name",
+			steps: extracting(models.Extraction{Variable: "a-b", Source: models.ExtractHeader, Property: "X-Id"}),
+			error: `step 1: invalid variable name "a-b"`,
+		},
+		{
+			name:  "invalid json path",
+			steps: extracting(models.Extraction{Variable: "v", Source: models.ExtractJSONPath, Property: "token"}),
+			error: "step 1: ",
+		},
+		{
+			name:  "header without name",
+			steps: extracting(models.Extraction{Variable: "v", Source: models.ExtractHeader, Property: " "}),
+			error: "step 1: header extraction of v requires a header name",
+		},
+		{
+			name:  "invalid regex",
+			steps: extracting(models.Extraction{Variable: "v", Source: models.ExtractRegex, Property: "("}),
+			error: `step 1: invalid regular expression "("`,
+		},
+		{
+			name:  "unknown source",
+			steps: extracting(models.Extraction{Variable: "v", Source: "cookie", Property: "session"}),
+			error: `step 1: unknown extraction source "cookie"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTransaction(tt.steps)
+			if tt.error == "" {
+				if err != nil {
+					t.Errorf("ValidateTransaction() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.error) {
+				t.Errorf("ValidateTransaction() error = %v, want %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestProbeTransaction(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1"})
+			io.WriteString(w, `{"token":"a b&c"}`)
+		case "/orders":
+			cookie, err := r.Cookie("session")
+			if err != nil || cookie.Value != "s1" || r.Header.Get("Authorization") != "Bearer a b&c" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Location", "/orders/42")
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	steps := []models.TransactionStep{
+		{
+			Name:    "login",
+			Method:  http.MethodPost,
+			URL:     server.URL + "/login",
+			Extract: []models.Extraction{{Variable: "token", Source: models.ExtractJSONPath, Property: "$.token"}},
+		},
+		{
+			Name:    "create order",
+			Method:  http.MethodPost,
+			URL:     server.URL + "/orders?token={{token}}",
+			Headers: map[string]string{"Authorization": "Bearer {{token}}"},
+			Extract: []models.Extraction{{Variable: "order", Source: models.ExtractHeader, Property: "Location"}},
+		},
+		{
+			Name: "read order",
+			URL:  server.URL + "{{order}}",
+		},
+		{
+			Name: "never run",
+			URL:  server.URL + "/login",
+		},
+	}
+
+	check := probeTransaction(context.Background(), &models.Endpoint{Type: models.TypeTransaction, Steps: steps})
+
+	if check.Result != ResultError {
+		t.Errorf("Result = %q, want %q", check.Result, ResultError)
+	}
+	if want := "Step 3 (read order): Unexpected status code 404"; check.Error != want {
+		t.Errorf("Error = %q, want %q", check.Error, want)
+	}
+	if len(check.Steps) != 3 {
+		t.Fatalf("got %d step results, want 3", len(check.Steps))
+	}
+	for i, want := range []string{ResultOK, ResultOK, ResultError} {
+		if check.Steps[i].Result != want {
+			t.Errorf("step %d Result = %q, want %q (error %q)", i+1, check.Steps[i].Result, want, check.Steps[i].Error)
+		}
+	}
+
+	// Extracted values are escaped in URLs, and the run stops at the failing step
+	wantRequests := []string{"/login", "/orders?token=a+b%26c", "/orders/42"}
+	if strings.Join(requests, " ") != strings.Join(wantRequests, " ") {
+		t.Errorf("requests = %v, want %v", requests, wantRequests)
+	}
+}
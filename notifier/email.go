@@ -0,0 +1,119 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPConfig holds the settings of the outgoing mail server
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// smtpConfig is the mail server used by email channels
+var smtpConfig = SMTPConfig{Port: "25", From: "api-monitor@localhost"}
+
+// SetSMTPConfig sets the mail server used by email channels
+func SetSMTPConfig(config SMTPConfig) {
+	smtpConfig = config
+}
+
+// Email sends events as plain text mail through an SMTP server
+type Email struct {
+	Config SMTPConfig
+	To     []string
+}
+
+// NewEmail creates an email notifier for a comma-separated list of recipients
+func NewEmail(config SMTPConfig, recipients string) (*Email, error) {
+	var to []string
+	for _, r := range strings.Split(recipients, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email address %q", r)
+		}
+		to = append(to, addr.Address)
+	}
+	if len(to) == 0 {
+		return nil, errors.New("no email recipients")
+	}
+	return &Email{Config: config, To: to}, nil
+}
+
+// Notify sends the event to all recipients
+func (e *Email) Notify(ctx context.Context, event Event) error {
+	return e.send(ctx, event.Subject(), event.Text())
+}
+
+// SendEmail sends a plain text message to a single recipient through the configured mail server
+func SendEmail(to, subject, text string) error {
+	e, err := NewEmail(smtpConfig, to)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	return e.send(ctx, subject, text)
+}
+
+// send delivers a plain text message to all recipients
+func (e *Email) send(ctx context.Context, subject, text string) error {
+	if e.Config.Host == "" {
+		return errors.New("SMTP server is not configured")
+	}
+
+	msg := "From: " + e.Config.From + "\r\n" +
+		"To: " + strings.Join(e.To, ", ") + "\r\n" +
+		"Subject: " + encodeHeader(subject) + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		strings.ReplaceAll(text, "\n", "\r\n") + "\r\n"
+
+	var auth smtp.Auth
+	if e.Config.Username != "" {
+		auth = smtp.PlainAuth("", e.Config.Username, e.Config.Password, e.Config.Host)
+	}
+
+	addr := net.JoinHostPort(e.Config.Host, e.Config.Port)
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, e.Config.From, e.To, []byte(msg))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// encodeHeader prepares text for a header value. Line breaks are replaced so
+// that text containing user input cannot add headers, and non-ASCII text is
+// encoded as RFC 2047 requires.
+func encodeHeader(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return ' '
+		}
+		return r
+	}, text)
+	return mime.QEncoding.Encode("utf-8", text)
+}
@@ -0,0 +1,221 @@
+package notifier
+
+import (
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// fakeSMTP is a minimal SMTP server that accepts one message and records the session
+type fakeSMTP struct {
+	listener net.Listener
+	auth     bool // Advertise AUTH PLAIN
+
+	commands    []string
+	credentials string // Decoded AUTH PLAIN response
+	from        string
+	to          []string
+	data        string
+	done        chan struct{}
+}
+
+// newFakeSMTP starts a fake SMTP server on a local port
+func newFakeSMTP(t *testing.T, auth bool) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{listener: listener, auth: auth, done: make(chan struct{})}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+// config returns mail server settings pointing at the fake server
+func (s *fakeSMTP) config() SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return SMTPConfig{Host: host, Port: port, From: "monitor@example.com"}
+}
+
+// serve handles a single SMTP session
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		s.commands = append(s.commands, line)
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			if s.auth {
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 AUTH PLAIN")
+			} else {
+				tp.PrintfLine("250 localhost")
+			}
+		case "AUTH":
+			_, encoded, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(encoded)
+			s.credentials = string(decoded)
+			tp.PrintfLine("235 Authentication succeeded")
+		case "MAIL":
+			s.from = arg
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.to = append(s.to, arg)
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 Go ahead")
+			lines, err := tp.ReadDotLines()
+			if err != nil {
+				return
+			}
+			s.data = strings.Join(lines, "\n")
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// withSMTPConfig sets the mail server used by SendEmail for the duration of a test
+func withSMTPConfig(t *testing.T, config SMTPConfig) {
+	previous := smtpConfig
+	SetSMTPConfig(config)
+	t.Cleanup(func() { SetSMTPConfig(previous) })
+}
+
+func TestSendEmail(t *testing.T) {
+	server := newFakeSMTP(t, false)
+	withSMTPConfig(t, server.config())
+
+	if err := SendEmail("ops@example.com", "[DOWN] https://example.com", "Endpoint is DOWN.\nHTTP status: 503"); err != nil {
+		t.Fatalf("SendEmail() error = %v", err)
+	}
+	<-server.done
+
+	for _, command := range server.commands {
+		if strings.HasPrefix(command, "STARTTLS") || strings.HasPrefix(command, "AUTH") {
+			t.Errorf("client sent %q to a server that does not offer it", command)
+		}
+	}
+	if server.from != "FROM:<monitor@example.com>" {
+		t.Errorf("MAIL %s, want FROM:<monitor@example.com>", server.from)
+	}
+	if len(server.to) != 1 || server.to[0] != "TO:<ops@example.com>" {
+		t.Errorf("RCPT %v, want [TO:<ops@example.com>]", server.to)
+	}
+
+	for _, want := range []string{
+		"From: monitor@example.com",
+		"To: ops@example.com",
+		"Subject: [DOWN] https://example.com",
+		"Content-Type: text/plain; charset=utf-8",
+		"\n\nEndpoint is DOWN.\nHTTP status: 503",
+	} {
+		if !strings.Contains(server.data, want) {
+			t.Errorf("message does not contain %q:\n%s", want, server.data)
+		}
+	}
+}
+
+func TestSendEmailAuth(t *testing.T) {
+	server := newFakeSMTP(t, true)
+	config := server.config()
+	config.Username = "monitor"
+	config.Password = "secret"
+	withSMTPConfig(t, config)
+
+	if err := SendEmail("ops@example.com", "Subject", "Body"); err != nil {
+		t.Fatalf("SendEmail() error = %v", err)
+	}
+	<-server.done
+
+	if server.credentials != "\x00monitor\x00secret" {
+		t.Errorf("AUTH PLAIN credentials %q, want %q", server.credentials, "\x00monitor\x00secret")
+	}
+	if !strings.Contains(server.data, "\n\nBody") {
+		t.Errorf("message does not contain the body:\n%s", server.data)
+	}
+}
+
+func TestSendEmailSubjectInjection(t *testing.T) {
+	server := newFakeSMTP(t, false)
+	withSMTPConfig(t, server.config())
+
+	if err := SendEmail("ops@example.com", "Invitation to join Acme\r\nBcc: attacker@example.com", "Body"); err != nil {
+		t.Fatalf("SendEmail() error = %v", err)
+	}
+	<-server.done
+
+	if !strings.Contains(server.data, "Subject: Invitation to join Acme  Bcc: attacker@example.com\n") {
+		t.Errorf("subject not kept on a single line:\n%s", server.data)
+	}
+	for _, line := range strings.Split(server.data, "\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Errorf("message has an injected header %q", line)
+		}
+	}
+	if len(server.to) != 1 {
+		t.Errorf("RCPT %v, want only the recipient", server.to)
+	}
+}
+
+func TestEncodeHeader(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"[DOWN] https://example.com", "[DOWN] https://example.com"},
+		{"line\r\nBcc: x@example.com", "line  Bcc: x@example.com"},
+		{"Café status", "=?utf-8?q?Caf=C3=A9_status?="},
+	}
+
+	for _, tt := range tests {
+		if got := encodeHeader(tt.text); got != tt.want {
+			t.Errorf("encodeHeader(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSendEmailNotConfigured(t *testing.T) {
+	withSMTPConfig(t, SMTPConfig{Port: "25", From: "monitor@example.com"})
+
+	if err := SendEmail("ops@example.com", "Subject", "Body"); err == nil {
+		t.Error("SendEmail() error = nil, want an error without an SMTP host")
+	}
+}
+
+func TestNewEmailRecipients(t *testing.T) {
+	email, err := NewEmail(SMTPConfig{}, "a@example.com, Bob <b@example.com>,")
+	if err != nil {
+		t.Fatalf("NewEmail() error = %v", err)
+	}
+	if strings.Join(email.To, ",") != "a@example.com,b@example.com" {
+		t.Errorf("To = %v, want [a@example.com b@example.com]", email.To)
+	}
+
+	for _, recipients := range []string{"", " , ", "not an address"} {
+		if _, err := NewEmail(SMTPConfig{}, recipients); err == nil {
+			t.Errorf("NewEmail(%q) error = nil, want an error", recipients)
+		}
+	}
+}
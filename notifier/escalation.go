@@ -0,0 +1,154 @@
+package notifier
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"api-monitor/broker"
+	"api-monitor/database"
+)
+
+// Escalate notifies the levels of an incident's escalation policy that are due,
+// starting with the first level right after the incident opened
+func Escalate(incidentID uint) {
+	incident, err := database.GetIncident(incidentID)
+	if err != nil {
+		log.Printf("Failed to load incident %d for escalation: %v", incidentID, err)
+		return
+	}
+	escalate(incident, time.Now())
+}
+
+// EscalateIncidents moves unacknowledged incidents up their escalation policy
+// and repeats the notifications that are due. It is called periodically.
+func EscalateIncidents() {
+	incidents, err := database.GetEscalatingIncidents()
+	if err != nil {
+		log.Printf("Failed to load escalating incidents: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range incidents {
+		escalate(&incidents[i], now)
+	}
+}
+
+// escalate notifies the levels of an open incident that became due since it was
+// last escalated, or repeats the notification of its current level
+func escalate(incident *database.Incident, now time.Time) {
+	if incident.Status != database.IncidentOpen || incident.EscalationPolicyID == nil {
+		return
+	}
+
+	policy, err := database.GetEscalationPolicy(*incident.EscalationPolicyID)
+	if err != nil {
+		log.Printf("Failed to load escalation policy %d of incident %d: %v", *incident.EscalationPolicyID, incident.ID, err)
+		return
+	}
+	due := policy.DueLevel(incident.StartedAt, now)
+	if due == 0 {
+		return
+	}
+
+	var levels []int
+	var message string
+	if due > incident.EscalationLevel {
+		for level := incident.EscalationLevel + 1; level <= due; level++ {
+			levels = append(levels, level)
+		}
+		message = fmt.Sprintf("Escalated to level %d", due)
+		if due == 1 {
+			message = "Notified level 1"
+		}
+	} else {
+		repeat := time.Duration(policy.Levels[due-1].RepeatInterval) * time.Minute
+		if repeat == 0 || incident.LastEscalatedAt == nil || now.Sub(*incident.LastEscalatedAt) < repeat {
+			return
+		}
+		levels = []int{due}
+		message = fmt.Sprintf("Repeated level %d notification", due)
+	}
+
+	claimed, err := database.ClaimEscalation(incident, due, now, message)
+	if err != nil {
+		log.Printf("Failed to escalate incident %d: %v", incident.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	broker.Publish(broker.Event{
+		Type:           broker.EventIncident,
+		OrganizationID: incident.OrganizationID,
+		EndpointID:     incident.EndpointID,
+		Data: broker.IncidentChange{
+			IncidentID: incident.ID,
+			Status:     incident.Status,
+			Message:    message,
+		},
+	})
+
+	var channelIDs []uint
+	for _, level := range levels {
+		channelIDs = append(channelIDs, policy.Levels[level-1].ChannelIDs...)
+	}
+
+	event := incidentEvent(incident)
+	event.EscalationLevel = due
+	dispatchTo(incident.OrganizationID, channelIDs, event)
+}
+
+// DispatchIncident sends an event about an incident. Incidents routed through an
+// escalation policy only reach the channels of the levels notified so far,
+// other incidents reach every active channel of the organization.
+func DispatchIncident(incidentID uint, event Event) {
+	incident, err := database.GetIncident(incidentID)
+	if err != nil {
+		log.Printf("Failed to load incident %d: %v", incidentID, err)
+		return
+	}
+	if incident.EscalationPolicyID == nil {
+		Dispatch(incident.OrganizationID, event)
+		return
+	}
+
+	policy, err := database.GetEscalationPolicy(*incident.EscalationPolicyID)
+	if err != nil {
+		log.Printf("Failed to load escalation policy %d of incident %d: %v", *incident.EscalationPolicyID, incident.ID, err)
+		return
+	}
+
+	var channelIDs []uint
+	for i := 0; i < incident.EscalationLevel && i < len(policy.Levels); i++ {
+		channelIDs = append(channelIDs, policy.Levels[i].ChannelIDs...)
+	}
+	dispatchTo(incident.OrganizationID, channelIDs, event)
+}
+
+// dispatchTo sends an event through the given active notification channels of an organization
+func dispatchTo(orgID uint, channelIDs []uint, event Event) {
+	channels, err := database.GetActiveChannelsByID(orgID, channelIDs)
+	if err != nil {
+		log.Printf("Failed to load notification channels for organization %d: %v", orgID, err)
+		return
+	}
+	sendAll(channels, event)
+}
+
+// incidentEvent builds the DOWN event of an incident
+func incidentEvent(incident *database.Incident) Event {
+	event := Event{
+		Type:       EventDown,
+		EndpointID: incident.EndpointID,
+		Error:      incident.Cause,
+		Time:       incident.StartedAt,
+	}
+	if endpoint, err := database.GetEndpoint(incident.EndpointID); err == nil {
+		event.URL = endpoint.URL
+	} else {
+		log.Printf("Failed to load endpoint %d of incident %d: %v", incident.EndpointID, incident.ID, err)
+	}
+	return event
+}
@@ -0,0 +1,121 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"api-monitor/database"
+)
+
+// EventType identifies what happened to an endpoint
+type EventType string
+
+const (
+	EventDown      EventType = "DOWN"
+	EventRecovered EventType = "RECOVERED"
+	EventTest      EventType = "TEST"
+
+	EventCertExpiring    EventType = "CERT_EXPIRING"
+	EventFlappingStarted EventType = "FLAPPING"
+	EventFlappingStopped EventType = "FLAPPING_STOPPED"
+)
+
+// sendTimeout bounds how long a single channel may take to deliver an event
+const sendTimeout = 10 * time.Second
+
+// Event describes an endpoint state change that should be sent to a user
+type Event struct {
+	Type       EventType `json:"type"`
+	EndpointID int       `json:"endpoint_id"`
+	URL        string    `json:"url"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+
+	CertExpiresAt   *time.Time `json:"cert_expires_at,omitempty"`  // Set for certificate expiry warnings
+	EscalationLevel int        `json:"escalation_level,omitempty"` // Set when sent by an escalation policy
+}
+
+// Subject returns a one-line description of the event
+func (e Event) Subject() string {
+	return fmt.Sprintf("[%s] %s", e.Type, e.URL)
+}
+
+// Text returns a human readable description of the event
+func (e Event) Text() string {
+	if e.Type == EventCertExpiring && e.CertExpiresAt != nil {
+		return fmt.Sprintf("The TLS certificate of endpoint %s expires on %s.", e.URL, e.CertExpiresAt.Format(time.RFC1123))
+	}
+
+	var text string
+	switch e.Type {
+	case EventFlappingStarted:
+		text = fmt.Sprintf("Endpoint %s is flapping as of %s. Alerts are suppressed until it is stable.", e.URL, e.Time.Format(time.RFC1123))
+	case EventFlappingStopped:
+		text = fmt.Sprintf("Endpoint %s stopped flapping as of %s.", e.URL, e.Time.Format(time.RFC1123))
+	default:
+		text = fmt.Sprintf("Endpoint %s is %s as of %s.", e.URL, e.Type, e.Time.Format(time.RFC1123))
+	}
+	if e.StatusCode != 0 {
+		text += fmt.Sprintf("\nHTTP status: %d", e.StatusCode)
+	}
+	if e.Error != "" {
+		text += "\nError: " + e.Error
+	}
+	if e.EscalationLevel != 0 {
+		text += fmt.Sprintf("\nEscalation level: %d", e.EscalationLevel)
+	}
+	return text
+}
+
+// Notifier delivers events to a single destination
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// New builds the notifier for a notification channel
+func New(channel database.NotificationChannel) (Notifier, error) {
+	switch channel.Type {
+	case database.ChannelTypeWebhook:
+		return NewWebhook(channel.Target)
+	case database.ChannelTypeSlack:
+		return NewSlack(channel.Target)
+	case database.ChannelTypeEmail:
+		return NewEmail(smtpConfig, channel.Target)
+	default:
+		return nil, fmt.Errorf("unknown channel type %q", channel.Type)
+	}
+}
+
+// Dispatch sends an event through all active notification channels of an organization
+func Dispatch(orgID uint, event Event) {
+	channels, err := database.GetActiveChannels(orgID)
+	if err != nil {
+		log.Printf("Failed to load notification channels for organization %d: %v", orgID, err)
+		return
+	}
+	sendAll(channels, event)
+}
+
+// sendAll delivers an event through each of the given channels, logging failures
+func sendAll(channels []database.NotificationChannel, event Event) {
+	for _, channel := range channels {
+		if err := Send(channel, event); err != nil {
+			log.Printf("Failed to send %s notification via channel %d (%s): %v", event.Type, channel.ID, channel.Type, err)
+		}
+	}
+}
+
+// Send delivers an event through a single notification channel
+func Send(channel database.NotificationChannel, event Event) error {
+	n, err := New(channel)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	return n.Notify(ctx, event)
+}
@@ -0,0 +1,28 @@
+package notifier
+
+import (
+	"context"
+	"net/http"
+)
+
+// Slack posts events to a Slack-compatible incoming webhook
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewSlack creates a Slack notifier for the given incoming webhook URL
+func NewSlack(target string) (*Slack, error) {
+	if err := validateURL(target); err != nil {
+		return nil, err
+	}
+	return &Slack{URL: target, Client: http.DefaultClient}, nil
+}
+
+// Notify posts the event as a message to the incoming webhook
+func (s *Slack) Notify(ctx context.Context, event Event) error {
+	payload := map[string]string{
+		"text": "*" + event.Subject() + "*\n" + event.Text(),
+	}
+	return postJSON(ctx, s.Client, s.URL, payload)
+}
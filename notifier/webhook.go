@@ -0,0 +1,63 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Webhook posts events as JSON to an HTTP endpoint
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook creates a webhook notifier for the given URL
+func NewWebhook(target string) (*Webhook, error) {
+	if err := validateURL(target); err != nil {
+		return nil, err
+	}
+	return &Webhook{URL: target, Client: http.DefaultClient}, nil
+}
+
+// Notify posts the event to the webhook URL
+func (w *Webhook) Notify(ctx context.Context, event Event) error {
+	return postJSON(ctx, w.Client, w.URL, event)
+}
+
+// postJSON sends payload as a JSON POST request and fails on non-2xx responses
+func postJSON(ctx context.Context, client *http.Client, target string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// validateURL checks that target is an absolute http(s) URL
+func validateURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q", target)
+	}
+	return nil
+}
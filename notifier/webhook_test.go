@@ -0,0 +1,81 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotify(t *testing.T) {
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s request with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhook(server.URL)
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+
+	event := Event{Type: EventDown, EndpointID: 7, URL: "https://example.com", StatusCode: 503, Time: time.Now().UTC()}
+	if err := webhook.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if received.Type != EventDown || received.EndpointID != 7 || received.StatusCode != 503 {
+		t.Errorf("received %+v, want %+v", received, event)
+	}
+}
+
+func TestSlackNotify(t *testing.T) {
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	slack, err := NewSlack(server.URL)
+	if err != nil {
+		t.Fatalf("NewSlack() error = %v", err)
+	}
+
+	event := Event{Type: EventRecovered, URL: "https://example.com", Time: time.Now()}
+	if err := slack.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if !strings.HasPrefix(payload["text"], "*[RECOVERED] https://example.com*\n") {
+		t.Errorf("text = %q, want it to start with the subject", payload["text"])
+	}
+}
+
+func TestNotifyErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhook(server.URL)
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+	if err := webhook.Notify(context.Background(), Event{Type: EventTest}); err == nil {
+		t.Error("Notify() error = nil, want an error for a 500 response")
+	}
+}
+
+func TestNewWebhookInvalidURL(t *testing.T) {
+	for _, target := range []string{"", "ftp://example.com", "example.com/hook", "http://"} {
+		if _, err := NewWebhook(target); err == nil {
+			t.Errorf("NewWebhook(%q) error = nil, want an error", target)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package scheduler
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-monitor/models"
+)
+
+// Default pool settings
+const (
+	DefaultWorkers    = 50
+	DefaultQueueSize  = 1000
+	DefaultMaxPerHost = 5
+)
+
+// Reasons passed to a SkipFunc
+const (
+	SkipInFlight  = "previous check still in flight"
+	SkipQueueFull = "check queue is full"
+)
+
+// SkipFunc is called for a due check that will not run
+type SkipFunc func(endpoint *models.Endpoint, reason string)
+
+// Config controls how many checks run at once
+type Config struct {
+	Workers    int // Checks running concurrently across all endpoints
+	QueueSize  int // Due checks waiting for a worker before further checks are skipped
+	MaxPerHost int // Checks running concurrently against a single host, 0 for no limit
+}
+
+// PoolStats reports the load of the worker pool
+type PoolStats struct {
+	Workers       int   `json:"workers"`
+	Busy          int64 `json:"busy"`           // Workers running a check
+	Queued        int   `json:"queued"`         // Checks waiting for a worker
+	QueueCapacity int   `json:"queue_capacity"` // Checks that may wait before new ones are skipped
+	Submitted     int64 `json:"submitted"`      // Checks accepted into the queue
+	Completed     int64 `json:"completed"`
+	SkippedBusy   int64 `json:"skipped_in_flight"`  // Checks skipped because the previous one had not finished
+	SkippedFull   int64 `json:"skipped_queue_full"` // Checks skipped because the queue was full
+	WaitingHost   int   `json:"waiting_for_host"`   // Checks waiting for a free slot on their host
+	AvgQueueWait  int64 `json:"avg_queue_wait"`     // in milliseconds, over completed checks
+}
+
+// job is a check waiting in the queue
+type job struct {
+	endpoint models.Endpoint
+	queuedAt time.Time
+}
+
+// hostSlots tracks the checks of a host that is limited to MaxPerHost at once
+type hostSlots struct {
+	running int
+	waiting []job // Checks to run as soon as a running one finishes
+}
+
+// pool runs checks on a fixed number of workers fed by a bounded queue
+type pool struct {
+	check  CheckFunc
+	skip   SkipFunc
+	config Config
+	queue  chan job
+
+	mu       sync.Mutex
+	inFlight map[int]bool          // Endpoints with a queued, waiting or running check
+	hosts    map[string]*hostSlots // Hosts with a running check, when MaxPerHost is set
+
+	busy, submitted, completed, skippedBusy, skippedFull, queueWait int64
+}
+
+// newPool creates a worker pool, filling in defaults for unset settings
+func newPool(check CheckFunc, skip SkipFunc, config Config) *pool {
+	if config.Workers <= 0 {
+		config.Workers = DefaultWorkers
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+
+	return &pool{
+		check:    check,
+		skip:     skip,
+		config:   config,
+		queue:    make(chan job, config.QueueSize),
+		inFlight: make(map[int]bool),
+		hosts:    make(map[string]*hostSlots),
+	}
+}
+
+// start launches the workers, which stop when ctx is cancelled
+func (p *pool) start(ctx context.Context) {
+	for i := 0; i < p.config.Workers; i++ {
+		go p.work(ctx)
+	}
+}
+
+// submit queues a check, or skips it if the endpoint is still being checked or the queue is full.
+// It never blocks so a slow check cannot hold up the scheduler.
+func (p *pool) submit(endpoint models.Endpoint) {
+	p.mu.Lock()
+	if p.inFlight[endpoint.ID] {
+		p.mu.Unlock()
+		atomic.AddInt64(&p.skippedBusy, 1)
+		go p.skip(&endpoint, SkipInFlight)
+		return
+	}
+
+	select {
+	case p.queue <- job{endpoint: endpoint, queuedAt: time.Now()}:
+		p.inFlight[endpoint.ID] = true
+		p.mu.Unlock()
+		atomic.AddInt64(&p.submitted, 1)
+	default:
+		p.mu.Unlock()
+		atomic.AddInt64(&p.skippedFull, 1)
+		go p.skip(&endpoint, SkipQueueFull)
+	}
+}
+
+// work runs queued checks until ctx is cancelled
+func (p *pool) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-p.queue:
+			p.run(ctx, j)
+		}
+	}
+}
+
+// run performs a check once its host has a free slot. A check of a host at
+// its limit waits for the host rather than holding a worker that checks of
+// other hosts could use; the worker finishing a check of the host then runs
+// the waiting ones. As an endpoint has at most one check in flight, at most
+// one check per endpoint waits.
+func (p *pool) run(ctx context.Context, j job) {
+	host := hostOf(&j.endpoint)
+	if !p.acquireHost(host, j) {
+		return
+	}
+
+	for {
+		p.runCheck(ctx, j)
+
+		next, ok := p.releaseHost(host)
+		if !ok || ctx.Err() != nil {
+			return
+		}
+		j = next
+	}
+}
+
+// runCheck performs a single check
+func (p *pool) runCheck(ctx context.Context, j job) {
+	defer func() {
+		p.mu.Lock()
+		delete(p.inFlight, j.endpoint.ID)
+		p.mu.Unlock()
+	}()
+
+	atomic.AddInt64(&p.queueWait, time.Since(j.queuedAt).Milliseconds())
+	atomic.AddInt64(&p.busy, 1)
+	p.check(ctx, &j.endpoint)
+	atomic.AddInt64(&p.busy, -1)
+	atomic.AddInt64(&p.completed, 1)
+}
+
+// acquireHost takes a slot on host for j, reporting whether j may run now.
+// Otherwise j waits for the host and is run by releaseHost's caller.
+func (p *pool) acquireHost(host string, j job) bool {
+	if p.config.MaxPerHost <= 0 || host == "" {
+		return true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slots, ok := p.hosts[host]
+	if !ok {
+		slots = &hostSlots{}
+		p.hosts[host] = slots
+	}
+	if slots.running < p.config.MaxPerHost {
+		slots.running++
+		return true
+	}
+	slots.waiting = append(slots.waiting, j)
+	return false
+}
+
+// releaseHost frees a slot on host, or hands it to the next check waiting
+// for the host, which the caller must run
+func (p *pool) releaseHost(host string) (job, bool) {
+	if p.config.MaxPerHost <= 0 || host == "" {
+		return job{}, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	slots := p.hosts[host]
+	if len(slots.waiting) > 0 {
+		next := slots.waiting[0]
+		slots.waiting = slots.waiting[1:]
+		return next, true
+	}
+	slots.running--
+	if slots.running == 0 {
+		delete(p.hosts, host)
+	}
+	return job{}, false
+}
+
+// stats returns a snapshot of the pool counters
+func (p *pool) stats() PoolStats {
+	stats := PoolStats{
+		Workers:       p.config.Workers,
+		Busy:          atomic.LoadInt64(&p.busy),
+		Queued:        len(p.queue),
+		QueueCapacity: cap(p.queue),
+		Submitted:     atomic.LoadInt64(&p.submitted),
+		Completed:     atomic.LoadInt64(&p.completed),
+		SkippedBusy:   atomic.LoadInt64(&p.skippedBusy),
+		SkippedFull:   atomic.LoadInt64(&p.skippedFull),
+	}
+
+	p.mu.Lock()
+	for _, slots := range p.hosts {
+		stats.WaitingHost += len(slots.waiting)
+	}
+	p.mu.Unlock()
+
+	if stats.Completed > 0 {
+		stats.AvgQueueWait = atomic.LoadInt64(&p.queueWait) / stats.Completed
+	}
+	return stats
+}
+
+// hostOf returns the host an endpoint's checks connect to
+func hostOf(endpoint *models.Endpoint) string {
+	switch endpoint.Type {
+	case models.TypeTCP, models.TypeGRPC:
+		host, _, err := net.SplitHostPort(endpoint.URL)
+		if err != nil {
+			return ""
+		}
+		return host
+	case models.TypeDNS:
+		// Lookups are limited per resolver, the system resolver is not limited
+		host, _, err := net.SplitHostPort(endpoint.Resolver)
+		if err != nil {
+			return ""
+		}
+		return host
+	case models.TypeHeartbeat:
+		// Heartbeats only read the database
+		return ""
+	case models.TypeTransaction:
+		// Transactions are limited by the host of their first step
+		if len(endpoint.Steps) == 0 {
+			return ""
+		}
+		u, err := url.Parse(endpoint.Steps[0].URL)
+		if err != nil {
+			return ""
+		}
+		return u.Hostname()
+	}
+
+	u, err := url.Parse(endpoint.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
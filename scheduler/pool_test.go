@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"api-monitor/models"
+)
+
+func TestPoolWaitsForBusyHost(t *testing.T) {
+	started := make(chan int, 3)
+	release := make(chan struct{})
+	skipped := make(chan string, 3)
+
+	check := func(ctx context.Context, endpoint *models.Endpoint) {
+		started <- endpoint.ID
+		if endpoint.ID == 1 {
+			<-release
+		}
+	}
+	skip := func(endpoint *models.Endpoint, reason string) {
+		skipped <- reason
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := newPool(check, skip, Config{Workers: 2, MaxPerHost: 1})
+	p.start(ctx)
+
+	p.submit(models.Endpoint{ID: 1, URL: "https://slow.example.com/a"})
+	if id := waitFor(t, started); id != 1 {
+		t.Fatalf("started endpoint %d, want 1", id)
+	}
+
+	// The slow host is at its limit, so its next check waits for it and
+	// leaves the other worker free for a different host
+	p.submit(models.Endpoint{ID: 2, URL: "https://slow.example.com/b"})
+	p.submit(models.Endpoint{ID: 3, URL: "https://fast.example.com"})
+	if id := waitFor(t, started); id != 3 {
+		t.Fatalf("started endpoint %d, want 3", id)
+	}
+	if stats := p.stats(); stats.WaitingHost != 1 {
+		t.Errorf("WaitingHost = %d, want 1", stats.WaitingHost)
+	}
+
+	// The waiting check runs once the slow one finishes
+	close(release)
+	if id := waitFor(t, started); id != 2 {
+		t.Errorf("started endpoint %d, want 2", id)
+	}
+	select {
+	case reason := <-skipped:
+		t.Errorf("skipped a check: %s", reason)
+	default:
+	}
+}
+
+// waitFor receives from ch or fails the test after a second
+func waitFor[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	var zero T
+	return zero
+}
@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"time"
+
+	"api-monitor/models"
+)
+
+// entry is a scheduled endpoint and the time of its next check
+type entry struct {
+	endpoint models.Endpoint
+	next     time.Time
+	index    int // Position in the queue, maintained by container/heap
+}
+
+// entryQueue is a min-heap of entries ordered by their next run time
+type entryQueue []*entry
+
+func (q entryQueue) Len() int { return len(q) }
+
+func (q entryQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+
+func (q entryQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *entryQueue) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *entryQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*q = old[:n-1]
+	return e
+}
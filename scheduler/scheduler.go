@@ -0,0 +1,251 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"api-monitor/database"
+	"api-monitor/models"
+)
+
+// idleWait is how long the run loop sleeps when nothing is scheduled
+const idleWait = time.Hour
+
+// CheckFunc performs a single check of an endpoint
+type CheckFunc func(ctx context.Context, endpoint *models.Endpoint)
+
+// Entry describes when an endpoint is checked next
+type Entry struct {
+	EndpointID int       `json:"endpoint_id"`
+	URL        string    `json:"url"`
+	Interval   int       `json:"interval"` // in seconds
+	NextRun    time.Time `json:"next_run"`
+}
+
+// Scheduler runs a check for every endpoint at its configured interval.
+// Endpoints are kept in a priority queue ordered by their next run time and
+// can be added, updated and removed while the scheduler is running.
+// Due checks are handed to a bounded worker pool.
+type Scheduler struct {
+	pool *pool
+
+	mu      sync.Mutex
+	queue   entryQueue
+	entries map[int]*entry
+	wake    chan struct{}
+}
+
+// New creates a scheduler that runs check for each due endpoint on a worker pool
+// and calls skip for due checks that cannot run
+func New(check CheckFunc, skip SkipFunc, config Config) *Scheduler {
+	return &Scheduler{
+		pool:    newPool(check, skip, config),
+		entries: make(map[int]*entry),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// LoadEndpoints schedules all non-expired endpoints from the database
+func (s *Scheduler) LoadEndpoints() error {
+	var dbEndpoints []database.Endpoint
+	if err := database.DB.Where("expires_at > ? OR expires_at IS NULL", time.Now()).Find(&dbEndpoints).Error; err != nil {
+		return err
+	}
+
+	for _, dbEndpoint := range dbEndpoints {
+		s.Add(dbEndpoint.ToModel())
+	}
+
+	log.Printf("Scheduled %d endpoints", len(dbEndpoints))
+	return nil
+}
+
+// Add schedules an endpoint. The first check runs after a random offset within
+// the interval so that endpoints sharing an interval do not all fire at once.
+// Adding an endpoint that is already scheduled updates it.
+func (s *Scheduler) Add(endpoint models.Endpoint) {
+	if endpoint.Interval <= 0 {
+		log.Printf("Not scheduling endpoint %d with invalid interval %d", endpoint.ID, endpoint.Interval)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[endpoint.ID]; ok {
+		s.update(e, endpoint)
+		return
+	}
+
+	e := &entry{
+		endpoint: endpoint,
+		next:     time.Now().Add(jitter(endpoint.Interval)),
+	}
+	s.entries[endpoint.ID] = e
+	heap.Push(&s.queue, e)
+	s.notify()
+}
+
+// Update replaces the settings of a scheduled endpoint, or schedules it if it is not scheduled yet
+func (s *Scheduler) Update(endpoint models.Endpoint) {
+	s.mu.Lock()
+	e, ok := s.entries[endpoint.ID]
+	if ok {
+		s.update(e, endpoint)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		s.Add(endpoint)
+	}
+}
+
+// update applies new endpoint settings to an entry. An endpoint whose interval
+// became invalid is no longer checked. The caller must hold s.mu.
+func (s *Scheduler) update(e *entry, endpoint models.Endpoint) {
+	if endpoint.Interval <= 0 {
+		log.Printf("Unscheduling endpoint %d with invalid interval %d", endpoint.ID, endpoint.Interval)
+		s.remove(e)
+		return
+	}
+
+	if endpoint.Interval != e.endpoint.Interval {
+		e.next = time.Now().Add(jitter(endpoint.Interval))
+		heap.Fix(&s.queue, e.index)
+		s.notify()
+	}
+	e.endpoint = endpoint
+}
+
+// Remove stops checking an endpoint
+func (s *Scheduler) Remove(endpointID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[endpointID]; ok {
+		s.remove(e)
+	}
+}
+
+// remove unschedules an entry. The caller must hold s.mu.
+func (s *Scheduler) remove(e *entry) {
+	heap.Remove(&s.queue, e.index)
+	delete(s.entries, e.endpoint.ID)
+	s.notify()
+}
+
+// Trigger moves the next check of a scheduled endpoint to now
+func (s *Scheduler) Trigger(endpointID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[endpointID]; ok {
+		e.next = time.Now()
+		heap.Fix(&s.queue, e.index)
+		s.notify()
+	}
+}
+
+// Entries returns the schedule of the given endpoints, ordered by next run time
+func (s *Scheduler) Entries(endpointIDs []int) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0, len(endpointIDs))
+	for _, id := range endpointIDs {
+		if e, ok := s.entries[id]; ok {
+			entries = append(entries, Entry{
+				EndpointID: id,
+				URL:        e.endpoint.URL,
+				Interval:   e.endpoint.Interval,
+				NextRun:    e.next,
+			})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].NextRun.Before(entries[j].NextRun) })
+	return entries
+}
+
+// Run dispatches due checks until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	s.pool.start(ctx)
+
+	timer := time.NewTimer(idleWait)
+	defer timer.Stop()
+
+	for {
+		wait := s.dispatchDue()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// dispatchDue starts the checks that are due and returns how long to wait for the next one
+func (s *Scheduler) dispatchDue() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for len(s.queue) > 0 {
+		e := s.queue[0]
+		if e.next.After(now) {
+			return e.next.Sub(now)
+		}
+
+		// Expired endpoints are dropped here and deleted by the expiry checker
+		if !e.endpoint.ExpiresAt.IsZero() && e.endpoint.ExpiresAt.Before(now) {
+			heap.Pop(&s.queue)
+			delete(s.entries, e.endpoint.ID)
+			continue
+		}
+
+		s.pool.submit(e.endpoint)
+
+		// Keep the phase of the schedule, but skip runs that were missed
+		interval := time.Duration(e.endpoint.Interval) * time.Second
+		e.next = e.next.Add(interval)
+		if !e.next.After(now) {
+			e.next = now.Add(interval)
+		}
+		heap.Fix(&s.queue, e.index)
+	}
+
+	return idleWait
+}
+
+// Stats reports the load of the worker pool
+func (s *Scheduler) Stats() PoolStats {
+	return s.pool.stats()
+}
+
+// notify wakes the run loop so it recomputes the next due time
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// jitter returns a random offset within an interval given in seconds
+func jitter(interval int) time.Duration {
+	return time.Duration(rand.Int63n(int64(time.Duration(interval) * time.Second)))
+}
@@ -0,0 +1,25 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+
+	"api-monitor/models"
+)
+
+func TestUpdateInvalidInterval(t *testing.T) {
+	s := New(func(context.Context, *models.Endpoint) {}, nil, Config{})
+
+	s.Add(models.Endpoint{ID: 1, URL: "https://example.com", Interval: 60})
+	if entries := s.Entries([]int{1}); len(entries) != 1 {
+		t.Fatalf("got %d entries after Add, want 1", len(entries))
+	}
+
+	s.Update(models.Endpoint{ID: 1, URL: "https://example.com", Interval: 0})
+	if entries := s.Entries([]int{1}); len(entries) != 0 {
+		t.Errorf("got %d entries after setting a zero interval, want 0", len(entries))
+	}
+	if len(s.queue) != 0 {
+		t.Errorf("queue has %d entries after setting a zero interval, want 0", len(s.queue))
+	}
+}